@@ -3,20 +3,21 @@ module main
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/adrg/frontmatter v0.2.0
 	github.com/fsnotify/fsnotify v1.6.0
 	github.com/rs/zerolog v1.29.1
 	github.com/urfave/cli/v2 v2.25.7
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/net v0.12.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
-	github.com/BurntSushi/toml v1.3.2 // indirect
-	github.com/adrg/frontmatter v0.2.0 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
-	golang.org/x/net v0.12.0 // indirect
 	golang.org/x/sys v0.10.0 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -1,12 +1,17 @@
 package application
 
 import (
+	"encoding/json"
+	"errors"
 	"html/template"
-	"io/ioutil"
-	"main/internal/converters"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
+
+	"main/internal/converters"
 
 	"github.com/rs/zerolog/log"
 )
@@ -51,16 +56,295 @@ type page struct {
 	absOutputPath  string
 	contentEntry   ContentEntry
 	fileNameNoExt  string
+	contents       []byte
+	changed        bool
+
+	// link and linkTitle are set on bookmarks, which have no contents.
+	link      string
+	linkTitle string
+}
+
+// source is a content file found during discovery along with the converter claiming it and
+// the entry of its page.
+type source struct {
+	relContentPath string
+	absolutePath   string
+	fileNameNoExt  string
+	extension      string
+	converter      converters.Converter
+	contentEntry   ContentEntry
+}
+
+// conversion is the outcome of converting a single source. page is nil for sources that
+// don't produce a page.
+type conversion struct {
+	record ManifestSource
+	page   *page
+	err    error
+}
+
+// convertSources converts sources on a bounded pool of workers. The result at index i
+// belongs to sources[i].
+func convertSources(config Config, prev Manifest, sources []source) []conversion {
+	jobs := config.Jobs
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+
+	conversions := make([]conversion, len(sources))
+	indices := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				conversions[i] = convertSource(config, prev, sources[i])
+			}
+		}()
+	}
+
+	for i := range sources {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	return conversions
+}
+
+func convertSource(config Config, prev Manifest, src source) conversion {
+	contentEntry := src.contentEntry
+
+	hash, err := hashFile(src.absolutePath)
+	if err != nil {
+		log.Error().Err(err).Str("file", src.absolutePath).Msg("Failed to hash file.")
+		return conversion{err: err}
+	}
+
+	// Reuse the cached conversion if the source hasn't changed since the last build
+	if record, ok := prev.Sources[src.relContentPath]; ok && record.Hash == hash {
+		if record.Page == "" {
+			if record.Link != "" {
+				return conversion{record: record, page: newLinkPage(config, src, contentEntry, record)}
+			}
+			return conversion{record: record}
+		}
+
+		contents, err := os.ReadFile(filepath.Join(config.CachePath, record.Cache))
+		if err == nil {
+			return conversion{
+				record: record,
+				page: &page{
+					url:            strings.TrimSuffix(record.Page, "index.html"),
+					relContentPath: src.relContentPath,
+					absContentPath: contentEntry.InputPath,
+					relOutputPath:  record.Page,
+					absOutputPath:  filepath.Join(config.BuildPath, record.Page),
+					contentEntry:   contentEntry,
+					fileNameNoExt:  src.fileNameNoExt,
+					contents:       contents,
+				},
+			}
+		}
+		log.Debug().Err(err).Str("file", src.relContentPath).Msg("Cached conversion is missing, converting again.")
+	}
+
+	result, err := src.converter.Convert(src.absolutePath, contentEntry.OutputPath)
+	if err != nil {
+		log.Error().Err(err).Str("converter", src.converter.Name()).Str("input", src.absolutePath).Str("output", contentEntry.OutputPath).Msg("Failed to convert file.")
+		return conversion{err: err}
+	}
+
+	record := ManifestSource{Hash: hash, Link: result.Metadata.Link, Title: result.Metadata.Title}
+	for _, asset := range result.Assets {
+		relAsset, err := filepath.Rel(config.BuildPath, asset)
+		if err != nil {
+			log.Error().Err(err).Str("file", asset).Msg("Failed to get relative path.")
+			return conversion{err: err}
+		}
+		record.Assets = append(record.Assets, relAsset)
+	}
+
+	if result.OutputPath == "" {
+		if result.Metadata.Link != "" {
+			log.Debug().Str("file", src.relContentPath).Str("link", result.Metadata.Link).Msg("Found a link.")
+			return conversion{record: record, page: newLinkPage(config, src, contentEntry, record)}
+		}
+		return conversion{record: record}
+	}
+
+	relOutputPath, err := filepath.Rel(config.BuildPath, result.OutputPath)
+	if err != nil {
+		log.Error().Err(err).Str("file", src.absolutePath).Msg("Failed to get relative path.")
+		return conversion{err: err}
+	}
+
+	contents, err := os.ReadFile(result.OutputPath)
+	if err != nil {
+		log.Error().Err(err).Str("file", result.OutputPath).Msg("Failed to read converted file.")
+		return conversion{err: err}
+	}
+
+	// Keep the converted page around, the build output gets overwritten by the template
+	record.Page = relOutputPath
+	record.Cache = filepath.Join("pages", hashBytes([]byte(src.relContentPath))[:16]+".html")
+	if err := os.WriteFile(filepath.Join(config.CachePath, record.Cache), contents, 0644); err != nil {
+		log.Error().Err(err).Str("file", src.relContentPath).Msg("Failed to cache converted file.")
+		return conversion{err: err}
+	}
+
+	return conversion{
+		record: record,
+		page: &page{
+			url:            strings.TrimSuffix(relOutputPath, "index.html"),
+			relContentPath: src.relContentPath,
+			absContentPath: contentEntry.InputPath,
+			relOutputPath:  relOutputPath,
+			absOutputPath:  result.OutputPath,
+			contentEntry:   contentEntry,
+			fileNameNoExt:  src.fileNameNoExt,
+			contents:       contents,
+			changed:        true,
+		},
+	}
 }
 
+// newLinkPage makes the page of a bookmark. It only has an output and a URL when links.redirect
+// is enabled, in which case a redirect to the link is written there.
+func newLinkPage(config Config, src source, contentEntry ContentEntry, record ManifestSource) *page {
+	p := &page{
+		relContentPath: src.relContentPath,
+		absContentPath: contentEntry.InputPath,
+		contentEntry:   contentEntry,
+		fileNameNoExt:  src.fileNameNoExt,
+		link:           record.Link,
+		linkTitle:      record.Title,
+	}
+	if config.Links.Redirect {
+		if relOutputPath, err := filepath.Rel(config.BuildPath, contentEntry.OutputPath); err == nil {
+			p.url = strings.TrimSuffix(relOutputPath, "index.html")
+			p.relOutputPath = relOutputPath
+			p.absOutputPath = contentEntry.OutputPath
+		}
+	}
+	return p
+}
+
+// Builder builds a site from its config. In watch mode the same builder is reused for
+// every rebuild so state like parsed templates carries over between builds.
+type Builder struct {
+	config         Config
+	templates      *TemplateSet
+	templateHashes map[string]string
+}
+
+func NewBuilder(config Config) *Builder {
+	return &Builder{config: config}
+}
+
+// ProcessFiles builds the site once.
 func ProcessFiles(config Config) error {
-	ResetDirectory(config.BuildPath)
+	_, err := NewBuilder(config).Build()
+	return err
+}
 
-	CopyDir(config.StaticPath, config.BuildPath)
+// BuildResult summarizes what a build changed in the build directory.
+type BuildResult struct {
+	// StaticChanged lists static files copied into the build directory, relative to it.
+	StaticChanged []string
+	// Rendered is the number of pages that were rendered.
+	Rendered int
+	// Removed is the number of stale outputs that were deleted.
+	Removed int
+	// Redirects lists the configured redirects and page aliases.
+	Redirects []Redirect
+}
+
+// StylesheetsOnly reports whether the build only changed static stylesheets.
+func (r BuildResult) StylesheetsOnly() bool {
+	if r.Rendered > 0 || r.Removed > 0 || len(r.StaticChanged) == 0 {
+		return false
+	}
+	for _, path := range r.StaticChanged {
+		if filepath.Ext(path) != ".css" {
+			return false
+		}
+	}
+	return true
+}
 
-	pages := make([]page, 0)
+func (b *Builder) Build() (BuildResult, error) {
+	var result BuildResult
+	config := b.config
+	prev := LoadManifest(config)
+	next := NewManifest()
 
-	err := filepath.Walk(config.ContentPath, func(filePath string, info os.FileInfo, err error) error {
+	configHash, err := hashFile(config.ConfigPath)
+	if err != nil {
+		log.Error().Err(err).Str("config", config.ConfigPath).Msg("Failed to hash config.")
+		return result, err
+	}
+	next.ConfigHash = configHash
+
+	// Anything that can't be attributed to individual files forces a full build
+	fullBuild := prev.Version != manifestVersion || prev.ConfigHash != configHash || !fileExists(config.BuildPath)
+	if fullBuild {
+		log.Info().Msg("Doing a full build.")
+		if err := ResetDirectory(config.BuildPath); err != nil {
+			return result, err
+		}
+		if err := os.RemoveAll(config.CachePath); err != nil {
+			log.Error().Err(err).Str("cachePath", config.CachePath).Msg("Failed to clear build cache.")
+			return result, err
+		}
+		prev = NewManifest()
+	}
+
+	next.Templates, err = hashTemplates(config.TemplatesPath)
+	if err != nil {
+		log.Error().Err(err).Str("templatesPath", config.TemplatesPath).Msg("Failed to hash templates.")
+		return result, err
+	}
+	changedTemplates := make(map[string]bool)
+	for path, hash := range next.Templates {
+		if prev.Templates[path] != hash {
+			changedTemplates[path] = true
+		}
+	}
+	for path := range prev.Templates {
+		if _, ok := next.Templates[path]; !ok {
+			changedTemplates[path] = true
+		}
+	}
+
+	// Only parse templates again when one of them changed since the last build
+	if b.templates == nil || !equalHashes(b.templateHashes, next.Templates) {
+		templates, err := LoadTemplateSet(config)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to load templates.")
+			return result, err
+		}
+		b.templates = templates
+		b.templateHashes = next.Templates
+	}
+
+	data, dataHash, err := LoadData(config)
+	if err != nil {
+		log.Error().Err(err).Str("dataPath", config.DataPath).Msg("Failed to load data files.")
+		return result, err
+	}
+	next.DataHash = dataHash
+
+	registry, err := NewConverterRegistry(config)
+	if err != nil {
+		return result, err
+	}
+
+	// Discover all sources first so they can be converted concurrently
+	now := time.Now()
+	sources := make([]source, 0)
+	err = filepath.Walk(config.ContentPath, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
 			log.Error().Err(err).Str("file", filePath).Msg("Error accessing file.")
 			return err
@@ -72,7 +356,6 @@ func ProcessFiles(config Config) error {
 		}
 
 		extension := filepath.Ext(info.Name())
-		fileName := strings.TrimSuffix(info.Name(), extension)
 
 		// Get the relative path of the input file
 		relContentPath, err := filepath.Rel(config.ContentPath, filePath)
@@ -81,84 +364,93 @@ func ProcessFiles(config Config) error {
 			return err
 		}
 
-		absolutePath := filepath.Join(config.ContentPath, relContentPath)
-
-		contentEntry := MatchContentEntry(config, absolutePath, extension == ".md")
-
-		// Create the output directory structure
-		outputPath := filepath.Join(config.BuildPath, filepath.Dir(relContentPath))
-		if err := os.MkdirAll(outputPath, 0755); err != nil {
-			log.Error().Err(err).Str("file", filePath).Msg("Failed to create output directory structure.")
-			return err
+		converter, ok := registry.Lookup(extension)
+		if !ok {
+			log.Info().Str("extension", extension).Str("file", relContentPath).Msg("Skipping file since extension is not supported.")
+			return nil
 		}
 
-		if extension == ".docx" || extension == ".rtf" || extension == ".odt" || extension == ".md" || extension == ".txt" || extension == ".rst" || extension == ".ipynb" {
-			outputFilePath, err := converters.ConvertFileToHTML(config.ContentPath, relContentPath, config.BuildPath, fileName)
-			if err != nil {
-				log.Error().Err(err).Str("input", absolutePath).Str("output", outputFilePath).Msg("Failed to convert file to HTML.")
-				return err
-			}
-
-			relOutputPath, err := filepath.Rel(config.BuildPath, outputFilePath)
-			if err != nil {
-				log.Error().Err(err).Str("file", filePath).Msg("Failed to get relative path.")
-				return err
-			}
-
-			pages = append(pages, page{
-				url:            strings.TrimSuffix(relOutputPath, "index.html"),
-				relContentPath: relContentPath,
-				absContentPath: contentEntry.InputPath,
-				relOutputPath:  relOutputPath,
-				absOutputPath:  contentEntry.OutputPath,
-				contentEntry:   contentEntry,
-				fileNameNoExt:  fileName,
-			})
-		} else if extension == ".html" {
-			CopyFile(absolutePath, outputPath)
-
-			relOutputPath, err := filepath.Rel(config.BuildPath, outputPath)
-			if err != nil {
-				log.Error().Err(err).Str("file", filePath).Msg("Failed to get relative path.")
-				return err
-			}
-
-			pages = append(pages, page{
-				url:            strings.TrimSuffix(relOutputPath, "index.html"),
-				relContentPath: relContentPath,
-				absContentPath: contentEntry.InputPath,
-				relOutputPath:  relOutputPath,
-				absOutputPath:  contentEntry.OutputPath,
-				contentEntry:   contentEntry,
-				fileNameNoExt:  fileName,
-			})
-		} else if extension == ".webloc" {
-			link, err := converters.ExtractLinkFromWebloc(config.ContentPath, relContentPath)
-			if err != nil {
-				log.Error().Err(err).Str("input", absolutePath).Msg("Failed to extract link from webloc.")
-				return err
-			}
-			log.Info().Str("file", relContentPath).Str("link", link).Msg("Found a webloc link, not doing anything it with.")
-		} else if extension == ".lnk" {
-			link, err := converters.ExtractLinkFromShortcut(config.ContentPath, relContentPath)
+		absolutePath := filepath.Join(config.ContentPath, relContentPath)
+		fm, ok := converter.(converters.FrontMatterConverter)
+		contentEntry := MatchContentEntry(config, absolutePath, ok && fm.HasFrontMatter(absolutePath))
+		if extractor, ok := converter.(converters.MetadataExtractor); ok {
+			metadata, err := extractor.ExtractMetadata(absolutePath)
 			if err != nil {
-				log.Error().Err(err).Str("input", absolutePath).Msg("Failed to extract link from lnk.")
-				return nil // TODO: don't ignore the error
+				log.Warn().Err(err).Str("file", relContentPath).Msg("Failed to extract document metadata.")
+			} else {
+				applyDocumentMetadata(&contentEntry, metadata)
 			}
-			log.Info().Str("file", relContentPath).Str("link", link).Msg("Found a webloc link, not doing anything it with.")
-		} else {
-			log.Info().Str("extension", extension).Str("file", relContentPath).Msg("Skipping file since extension is not supported.")
+		}
+		if !contentEntry.isPublished(config, now) {
+			// Left out of the manifest so it's looked at again next build
+			log.Info().Str("file", relContentPath).Msg("Skipping unpublished page, use --drafts or --future to include it.")
+			return nil
 		}
 
+		sources = append(sources, source{
+			relContentPath: relContentPath,
+			absolutePath:   absolutePath,
+			fileNameNoExt:  strings.TrimSuffix(info.Name(), extension),
+			extension:      extension,
+			converter:      converter,
+			contentEntry:   contentEntry,
+		})
+
 		return nil
 	})
+	if err != nil {
+		log.Error().Err(err).Msg("Error walking through input directory.")
+		return result, err
+	}
+
+	// Sources are converted straight into the build directory, so collisions have to be caught first
+	if err := checkOutputCollisions(config, sources); err != nil {
+		return result, err
+	}
+
+	result.StaticChanged, err = SyncStaticFiles(config, prev, &next)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to copy static files.")
+		return result, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(config.CachePath, "pages"), 0755); err != nil {
+		log.Error().Err(err).Str("cachePath", config.CachePath).Msg("Failed to create cache directory.")
+		return result, err
+	}
+
+	conversions := convertSources(config, prev, sources)
+
+	// Collect results in discovery order so the page list doesn't depend on scheduling
+	pages := make([]page, 0, len(conversions))
+	var errs []error
+	for i, c := range conversions {
+		if c.err != nil {
+			errs = append(errs, &FileError{Path: sources[i].absolutePath, Err: c.err})
+			continue
+		}
+		next.Sources[sources[i].relContentPath] = c.record
+		if c.page != nil {
+			pages = append(pages, *c.page)
+		}
+	}
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		log.Error().Err(err).Int("failed", len(errs)).Msg("Failed to convert files.")
+		return result, err
+	}
 
 	// transform prior repr of pages into list of TPage
 	tPages := make([]TPage, 0, len(pages))
 	for _, p := range pages {
+		if p.link != "" {
+			tPages = append(tPages, newLinkTPage(p, now))
+			continue
+		}
+
 		foundTitle := p.contentEntry.Title
 		if len(foundTitle) == 0 {
-			foundTitleP := GetTitleForHtmlFile(p.absOutputPath)
+			foundTitleP := GetTitleForHtml(p.contents)
 			if foundTitleP != nil {
 				foundTitle = *foundTitleP
 			} else {
@@ -172,6 +464,9 @@ func ProcessFiles(config Config) error {
 		}
 
 		foundCreationTime := p.contentEntry.CreatedAt
+		if foundCreationTime.IsZero() {
+			foundCreationTime = p.contentEntry.PublishAt
+		}
 		if foundCreationTime.IsZero() {
 			foundCreationTime = GetCreationTimeForFile(p.absContentPath)
 			if foundCreationTime.IsZero() {
@@ -180,6 +475,7 @@ func ProcessFiles(config Config) error {
 		}
 
 		tPages = append(tPages, TPage{
+			Kind:            PageKindPage,
 			SourcePath:      p.absContentPath,
 			TemplatePath:    p.contentEntry.Template,
 			DestinationPath: p.absOutputPath,
@@ -189,39 +485,199 @@ func ProcessFiles(config Config) error {
 			CreatedAt:       foundCreationTime,
 			Tags:            p.contentEntry.Tags,
 			Metadata:        p.contentEntry.Metadata,
+			SitemapExclude:  isTrue(p.contentEntry.SitemapExclude),
+			Aliases:         p.contentEntry.Aliases,
+			Paginated:       p.contentEntry.Paginate != nil,
+			Draft:           isTrue(p.contentEntry.Draft),
+			Scheduled:       p.contentEntry.PublishAt.After(now),
+			PublishAt:       p.contentEntry.PublishAt,
+			Contents:        template.HTML(p.contents),
 		})
 	}
 
+	// Every page can list every other page along with its contents, so a change to either
+	// re-renders all of them
+	pagesJson, err := json.Marshal(tPages)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to serialize page list.")
+		return result, err
+	}
 	for _, tPage := range tPages {
-		// Read the contents of the file
-		contents, err := ioutil.ReadFile(tPage.DestinationPath)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to read file.")
-		}
-
-		tData := TData{
-			Page:     tPage,
-			Contents: template.HTML(contents),
-			Pages: TPageList{
-				List: tPages,
-			},
-			Site: TSite{
-				Title:       config.SiteTitle,
-				Description: config.SiteDescription,
-			},
-		} // page.absOutputPath, page.contentEntry.Template, &pages_urls
-
-		err = ApplyTemplateToFile(tData)
+		pagesJson = append(pagesJson, hashBytes([]byte(tPage.Contents))...)
+	}
+	next.PagesHash = hashBytes(pagesJson)
+	renderAll := next.PagesHash != prev.PagesHash
+
+	// Every page can use the data files too
+	if next.DataHash != prev.DataHash {
+		renderAll = true
+	}
+
+	// And so can the files read by templates, which would otherwise go unnoticed
+	for path, hash := range prev.ReadFiles {
+		if current, err := hashFile(path); err != nil || current != hash {
+			renderAll = true
+			break
+		}
+	}
+	b.templates.reads.take()
+
+	// A changed template that isn't the main template of any page may be included by all of them
+	pageTemplates := make(map[string]bool)
+	for _, tPage := range tPages {
+		pageTemplates[tPage.TemplatePath] = true
+	}
+	for path := range changedTemplates {
+		if !pageTemplates[path] {
+			renderAll = true
+		}
+	}
+
+	base := TData{
+		Pages: TPageList(tPages),
+		Site: TSite{
+			Title:       config.SiteTitle,
+			Description: config.SiteDescription,
+			BaseUrl:     config.BaseUrl,
+			Language:    config.Language,
+			Author:      config.Author,
+			Params:      normalizeMetadata(config.Params),
+			Menus:       NewMenus(config),
+			Data:        data,
+		},
+		Tags: CollectTags(config, tPages),
+	}
+
+	for i, tPage := range tPages {
+		// Bookmarks aren't rendered, their redirects are generated with the others
+		if tPage.Kind == PageKindLink {
+			continue
+		}
+
+		var paginators []TPaginator
+		if paginate := pages[i].contentEntry.Paginate; paginate != nil {
+			paginators = Paginate(*paginate, tPage, tPages)
+
+			// Every page after the first is generated below the page itself
+			for n := 1; n < len(paginators); n++ {
+				tData := base
+				tData.Page = tPage
+				tData.Page.UrlPath = paginationUrl(tPage.UrlPath, n+1)
+				tData.Contents = template.HTML(pages[i].contents)
+				tData.Paginator = &paginators[n]
+				if err := renderGeneratedPage(config, b.templates, tData, &next); err != nil {
+					return result, err
+				}
+			}
+		}
+
+		if !renderAll && !pages[i].changed && !changedTemplates[tPage.TemplatePath] && fileExists(tPage.DestinationPath) {
+			continue
+		}
+
+		tData := base
+		tData.Page = tPage
+		tData.Contents = template.HTML(pages[i].contents)
+		if len(paginators) > 0 {
+			tData.Paginator = &paginators[0]
+		}
+
+		err = ApplyTemplateToFile(b.templates, tData)
 		if err != nil {
 			log.Error().Err(err).Any("tPage", tPage).Msg("Failed to apply template to file.")
-			return err
+			return result, err
+		}
+		result.Rendered++
+	}
+
+	if err := GenerateTagPages(config, b.templates, base, &next); err != nil {
+		return result, err
+	}
+
+	// Pages that weren't rendered again still depend on the files they read last time
+	next.ReadFiles = b.templates.reads.take()
+	if !renderAll {
+		for path, hash := range prev.ReadFiles {
+			if _, ok := next.ReadFiles[path]; !ok {
+				next.ReadFiles[path] = hash
+			}
 		}
 	}
 
+	if err := GenerateFeeds(config, tPages, now, &next); err != nil {
+		return result, err
+	}
+
+	if err := GenerateSitemap(config, tPages, &next); err != nil {
+		return result, err
+	}
+
+	if err := GenerateRobots(config, &next); err != nil {
+		return result, err
+	}
+
+	result.Redirects, err = GenerateRedirects(config, tPages, &next)
 	if err != nil {
-		log.Error().Err(err).Msg("Error walking through input directory.")
-		return err
+		return result, err
 	}
 
-	return nil
+	// Only now that every output is known, including generated ones, can stale ones go
+	result.Removed = removeStaleOutputs(config, prev, next)
+
+	log.Info().Int("pages", len(tPages)).Int("rendered", result.Rendered).Msg("Build complete.")
+
+	return result, SaveManifest(config, next)
+}
+
+// newLinkTPage describes a bookmark, titled after its file unless the bookmark or the content
+// entry has a title.
+func newLinkTPage(p page, now time.Time) TPage {
+	title := p.contentEntry.Title
+	if title == "" {
+		title = p.linkTitle
+	}
+	if title == "" {
+		title = p.fileNameNoExt
+	}
+
+	createdAt := p.contentEntry.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = p.contentEntry.PublishAt
+	}
+	if createdAt.IsZero() {
+		createdAt = GetCreationTimeForFile(p.absContentPath)
+	}
+
+	tPage := TPage{
+		Kind:            PageKindLink,
+		Link:            p.link,
+		SourcePath:      p.absContentPath,
+		DestinationPath: p.absOutputPath,
+		Title:           title,
+		Description:     p.contentEntry.Description,
+		CreatedAt:       createdAt,
+		Tags:            p.contentEntry.Tags,
+		Metadata:        p.contentEntry.Metadata,
+		SitemapExclude:  true,
+		Aliases:         p.contentEntry.Aliases,
+		Draft:           isTrue(p.contentEntry.Draft),
+		Scheduled:       p.contentEntry.PublishAt.After(now),
+		PublishAt:       p.contentEntry.PublishAt,
+	}
+	if p.relOutputPath != "" {
+		tPage.UrlPath = "/" + p.url
+	}
+	return tPage
+}
+
+func equalHashes(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
 }
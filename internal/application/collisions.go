@@ -0,0 +1,139 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"main/internal/converters"
+
+	"github.com/rs/zerolog/log"
+)
+
+// outputClaims maps outputs, relative to the build path, to what writes them.
+type outputClaims map[string]string
+
+// claim records that what writes relPath, which fails when something else writes it too.
+func (c outputClaims) claim(relPath string, what string) error {
+	if other, ok := c[relPath]; ok {
+		return fmt.Errorf("output path %s is also produced by %s", filepath.ToSlash(relPath), other)
+	}
+	c[relPath] = what
+	return nil
+}
+
+// checkOutputCollisions makes sure no two outputs of the build share a path before any source
+// is converted. Besides the pages of sources, these are static files, tag pages, the pages of
+// paginated lists and redirect stubs.
+func checkOutputCollisions(config Config, sources []source) error {
+	claims := make(outputClaims)
+	static, err := staticOutputs(config)
+	if err != nil {
+		log.Error().Err(err).Str("staticPath", config.StaticPath).Msg("Failed to list static files.")
+		return err
+	}
+	for _, relPath := range static {
+		claims[relPath] = "the static file " + filepath.ToSlash(relPath)
+	}
+
+	var errs []error
+	var redirects []Redirect
+	var tagNames []string
+	tagSlugs := make(map[string]bool)
+	// Paginated pages by the directory their other pages are written to, e.g. blog/page/
+	paginated := make(map[string]string)
+	for _, src := range sources {
+		entry := src.contentEntry
+		for _, name := range entry.Tags {
+			if slug := Slugify(name); slug != "" && !tagSlugs[slug] {
+				tagSlugs[slug] = true
+				tagNames = append(tagNames, name)
+			}
+		}
+
+		// Bookmarks only have an output when they redirect
+		lc, ok := src.converter.(converters.LinkConverter)
+		isLink := ok && lc.ConvertsLinks()
+		if isLink && !config.Links.Redirect {
+			continue
+		}
+
+		relOutputPath, err := filepath.Rel(config.BuildPath, entry.OutputPath)
+		if err != nil || relOutputPath == ".." || strings.HasPrefix(relOutputPath, ".."+string(filepath.Separator)) {
+			errs = append(errs, &FileError{Path: src.absolutePath, Err: fmt.Errorf("output path %s is outside of the build path", entry.OutputPath)})
+			continue
+		}
+		if err := claims.claim(relOutputPath, src.relContentPath); err != nil {
+			errs = append(errs, &FileError{Path: src.absolutePath, Err: err})
+			continue
+		}
+
+		urlPath := "/" + strings.TrimSuffix(filepath.ToSlash(relOutputPath), "index.html")
+		for _, alias := range entry.Aliases {
+			redirects = append(redirects, Redirect{From: "/" + strings.TrimPrefix(alias, "/"), To: urlPath})
+		}
+		if entry.Paginate != nil && !isLink {
+			dir := strings.TrimPrefix(strings.TrimSuffix(paginationUrl(urlPath, 2), "2/"), "/")
+			if other, ok := paginated[dir]; ok {
+				errs = append(errs, &FileError{Path: src.absolutePath, Err: fmt.Errorf("pagination below %s is also produced by %s", dir, other)})
+				continue
+			}
+			paginated[dir] = src.relContentPath
+		}
+	}
+
+	if config.Taxonomy.IndexTemplate != "" || config.Taxonomy.TagTemplate != "" {
+		tagsPath := filepath.FromSlash(strings.TrimPrefix(tagsUrlPath(config), "/"))
+		if config.Taxonomy.IndexTemplate != "" {
+			if err := claims.claim(filepath.Join(tagsPath, "index.html"), "the tag index"); err != nil {
+				errs = append(errs, fmt.Errorf("tag index: %w", err))
+			}
+		}
+		if config.Taxonomy.TagTemplate != "" {
+			for _, name := range tagNames {
+				if err := claims.claim(filepath.Join(tagsPath, Slugify(name), "index.html"), fmt.Sprintf("the page of tag %q", name)); err != nil {
+					errs = append(errs, fmt.Errorf("tag %q: %w", name, err))
+				}
+			}
+		}
+	}
+
+	for _, r := range config.Redirects {
+		if r.From != "" {
+			redirects = append(redirects, Redirect{From: "/" + strings.TrimPrefix(r.From, "/"), To: r.To})
+		}
+	}
+	for _, r := range redirects {
+		if err := claims.claim(redirectStubPath(r.From), "the redirect from "+r.From); err != nil {
+			errs = append(errs, fmt.Errorf("redirect from %s to %s: %w", r.From, r.To, err))
+		}
+	}
+
+	// Lists grow, so any output where a later page of a list would go collides with it
+	var claimed []string
+	for relPath := range claims {
+		claimed = append(claimed, relPath)
+	}
+	sort.Strings(claimed)
+	for _, relPath := range claimed {
+		for dir, owner := range paginated {
+			rest := strings.TrimPrefix(filepath.ToSlash(relPath), dir)
+			if rest == filepath.ToSlash(relPath) || !strings.HasSuffix(rest, "/index.html") {
+				continue
+			}
+			if n, err := strconv.Atoi(strings.TrimSuffix(rest, "/index.html")); err == nil && n > 1 {
+				errs = append(errs, fmt.Errorf("output path %s of %s is also produced by the pagination of %s", filepath.ToSlash(relPath), claims[relPath], owner))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		err := errors.Join(errs...)
+		log.Error().Err(err).Int("collisions", len(errs)).Msg("Found colliding outputs.")
+		return err
+	}
+	return nil
+}
@@ -1,12 +1,15 @@
 package application
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"main/internal/converters"
+
 	"github.com/adrg/frontmatter"
 	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v2"
@@ -18,31 +21,154 @@ type Config struct {
 	StaticPath      string         `yaml:"static_path"`
 	TemplatesPath   string         `yaml:"templates_path"`
 	BuildPath       string         `yaml:"build_path"`
+	DataPath        string         `yaml:"data_path,omitempty"`
+	CachePath       string         `yaml:"cache_path,omitempty"`
 	DefaultTemplate string         `yaml:"default_template"`
 	SiteTitle       string         `yaml:"site_title"`
 	SiteDescription string         `yaml:"site_description"`
+	BaseUrl         string         `yaml:"base_url,omitempty"`
+	PrettyUrls      bool           `yaml:"pretty_urls,omitempty"`
 	Content         []ContentEntry `yaml:"content"`
 
+	// Language and Author describe the site for templates, Params holds anything else they
+	// need, e.g. `params: {twitter: spot}` is {{ .Site.Params.twitter }}.
+	Language string                 `yaml:"language,omitempty"`
+	Author   string                 `yaml:"author,omitempty"`
+	Params   map[string]interface{} `yaml:"params,omitempty"`
+
+	// Menus are named navigation menus like `menus: {main: [{name: Blog, url: /blog/}]}`.
+	Menus map[string][]MenuEntry `yaml:"menus,omitempty"`
+
+	// Feeds lists the RSS, Atom and JSON feeds to generate.
+	Feeds []FeedEntry `yaml:"feeds,omitempty"`
+
+	// Taxonomy configures the generated tag index and per-tag listing pages.
+	Taxonomy TaxonomyEntry `yaml:"taxonomy,omitempty"`
+
+	// Redirects are extra redirects on top of the aliases declared by pages, RedirectFiles
+	// selects which server config files are generated for them.
+	Redirects     []RedirectEntry    `yaml:"redirects,omitempty"`
+	RedirectFiles RedirectFilesEntry `yaml:"redirect_files,omitempty"`
+
+	// Links configures the pages made from bookmark files like .webloc, .lnk and .url.
+	Links LinksEntry `yaml:"links,omitempty"`
+
+	// Sitemap and Robots configure the generated sitemap.xml and robots.txt.
+	Sitemap SitemapEntry `yaml:"sitemap,omitempty"`
+	Robots  RobotsEntry  `yaml:"robots,omitempty"`
+
+	// Drafts and Future include drafts and pages whose publish_at is still ahead, which are
+	// left out by default.
+	Drafts bool `yaml:"drafts,omitempty"`
+	Future bool `yaml:"future,omitempty"`
+
+	// Jobs bounds how many files are converted concurrently, defaulting to the number of CPUs.
+	Jobs int `yaml:"jobs,omitempty"`
+
+	// MarkdownRenderer selects how .md files are rendered, either "native" (default) or "pandoc".
+	MarkdownRenderer string `yaml:"markdown_renderer,omitempty"`
+
+	// ConverterExtensions maps additional file extensions onto converters by name, e.g. `.markdown: pandoc`.
+	ConverterExtensions map[string]string `yaml:"converter_extensions,omitempty"`
+
 	contentTrie pathTrie `yaml:"-"`
 }
 
 type ContentEntry struct {
-	InputPath   string            `yaml:"input_path"`
-	OutputPath  string            `yaml:"output_path"`
-	Template    string            `yaml:"template"`
-	Title       string            `yaml:"title"`
-	Description string            `yaml:"description"`
-	CreatedAt   time.Time         `yaml:"created_at"`
-	Tags        []string          `yaml:"tags"`
-	Metadata    map[string]string `yaml:"metadata"`
+	InputPath   string                 `yaml:"input_path"`
+	OutputPath  string                 `yaml:"output_path"`
+	Template    string                 `yaml:"template"`
+	Title       string                 `yaml:"title"`
+	Description string                 `yaml:"description"`
+	CreatedAt   time.Time              `yaml:"created_at"`
+	Tags        []string               `yaml:"tags"`
+	Metadata    map[string]interface{} `yaml:"metadata"`
+
+	SitemapExclude *bool          `yaml:"sitemap_exclude,omitempty"`
+	Paginate       *PaginateEntry `yaml:"paginate,omitempty"`
+	Aliases        []string       `yaml:"aliases,omitempty"`
+	Draft          *bool          `yaml:"draft,omitempty"`
+	PublishAt      time.Time      `yaml:"publish_at,omitempty"`
+
+	// PrettyUrls overrides the site-level pretty_urls for pages of this entry.
+	PrettyUrls *bool `yaml:"pretty_urls,omitempty"`
+}
+
+// PaginateEntry makes a page list a filtered collection of pages over several pages, e.g.
+// /blog/, /blog/page/2/, ... Filters that are left empty don't apply.
+type PaginateEntry struct {
+	UrlPrefix     string `yaml:"url_prefix" toml:"url_prefix" json:"url_prefix"`
+	Tag           string `yaml:"tag" toml:"tag" json:"tag"`
+	MetadataKey   string `yaml:"metadata_key" toml:"metadata_key" json:"metadata_key"`
+	MetadataValue string `yaml:"metadata_value" toml:"metadata_value" json:"metadata_value"`
+	PageSize      int    `yaml:"page_size" toml:"page_size" json:"page_size"`
+}
+
+type FeedEntry struct {
+	// OutputPath is the directory, relative to the build path, the feed files are written to.
+	OutputPath  string   `yaml:"output_path"`
+	Title       string   `yaml:"title"`
+	Description string   `yaml:"description"`
+	UrlPrefix   string   `yaml:"url_prefix"`
+	Tag         string   `yaml:"tag"`
+	Limit       int      `yaml:"limit"`
+	Formats     []string `yaml:"formats"`
+}
+
+type TaxonomyEntry struct {
+	// OutputPath is the directory, relative to the build path, tag pages are written to. Defaults to "tags".
+	OutputPath    string `yaml:"output_path"`
+	IndexTemplate string `yaml:"index_template"`
+	TagTemplate   string `yaml:"tag_template"`
+}
+
+type RedirectEntry struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	// Status is the HTTP status used by the generated server config files, defaults to 301.
+	Status int `yaml:"status"`
+}
+
+type RedirectFilesEntry struct {
+	// Netlify writes a _redirects file.
+	Netlify bool `yaml:"netlify"`
+	// Nginx writes redirects.map for use with an nginx map block, plus a redirects-<status>.map
+	// for every other status.
+	Nginx bool `yaml:"nginx"`
+}
+
+type LinksEntry struct {
+	// Redirect writes a page at the bookmark's own URL that redirects to the bookmarked link.
+	Redirect bool `yaml:"redirect"`
+}
+
+type SitemapEntry struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type RobotsEntry struct {
+	Enabled bool         `yaml:"enabled"`
+	Rules   []RobotsRule `yaml:"rules"`
+}
+
+type RobotsRule struct {
+	UserAgent string   `yaml:"user_agent"`
+	Allow     []string `yaml:"allow"`
+	Disallow  []string `yaml:"disallow"`
 }
 
+// FrontMatterEntry is the front matter of a markdown page, written as YAML, TOML or JSON.
 type FrontMatterEntry struct {
-	Title       string            `yaml:"title"`
-	Description string            `yaml:"description"`
-	CreatedAt   time.Time         `yaml:"created_at"`
-	Tags        []string          `yaml:"tags"`
-	Metadata    map[string]string `yaml:"metadata"`
+	Title          string                 `yaml:"title" toml:"title" json:"title"`
+	Description    string                 `yaml:"description" toml:"description" json:"description"`
+	CreatedAt      time.Time              `yaml:"created_at" toml:"created_at" json:"created_at"`
+	Tags           []string               `yaml:"tags" toml:"tags" json:"tags"`
+	Metadata       map[string]interface{} `yaml:"metadata" toml:"metadata" json:"metadata"`
+	SitemapExclude *bool                  `yaml:"sitemap_exclude" toml:"sitemap_exclude" json:"sitemap_exclude"`
+	Paginate       *PaginateEntry         `yaml:"paginate" toml:"paginate" json:"paginate"`
+	Aliases        []string               `yaml:"aliases" toml:"aliases" json:"aliases"`
+	Draft          *bool                  `yaml:"draft" toml:"draft" json:"draft"`
+	PublishAt      time.Time              `yaml:"publish_at" toml:"publish_at" json:"publish_at"`
 }
 
 type trieNode struct {
@@ -82,7 +208,9 @@ func (t *pathTrie) insert(path string, entry *ContentEntry) {
 	node.entry = entry
 }
 
-func (t *pathTrie) search(path string) *ContentEntry {
+// searchAll returns every entry on the way to path, from the least to the most specific.
+func (t *pathTrie) searchAll(path string) []*ContentEntry {
+	var entries []*ContentEntry
 	node := t.root
 	segments := strings.Split(path, "/")
 	for _, segment := range segments {
@@ -90,11 +218,11 @@ func (t *pathTrie) search(path string) *ContentEntry {
 			break
 		}
 		node = node.children[segment]
+		if node.isEnd {
+			entries = append(entries, node.entry)
+		}
 	}
-	if node.isEnd {
-		return node.entry
-	}
-	return nil
+	return entries
 }
 
 func ParseConfig(configPath string) (Config, error) {
@@ -134,9 +262,25 @@ func ParseConfig(configPath string) (Config, error) {
 	config.StaticPath = filepath.Join(basePath, config.StaticPath)
 	config.TemplatesPath = filepath.Join(basePath, config.TemplatesPath)
 	config.BuildPath = filepath.Join(basePath, config.BuildPath)
+	if len(config.DataPath) > 0 {
+		config.DataPath = filepath.Join(basePath, config.DataPath)
+	}
+	config.BaseUrl = strings.TrimSuffix(config.BaseUrl, "/")
+	if len(config.CachePath) > 0 {
+		config.CachePath = filepath.Join(basePath, config.CachePath)
+	} else {
+		// Keep the build cache and manifest next to the build output, e.g. dist/ and .dist-cache/
+		config.CachePath = filepath.Join(filepath.Dir(config.BuildPath), "."+filepath.Base(config.BuildPath)+"-cache")
+	}
 	if len(config.DefaultTemplate) > 0 {
 		config.DefaultTemplate = filepath.Join(config.TemplatesPath, config.DefaultTemplate)
 	}
+	if len(config.Taxonomy.IndexTemplate) > 0 {
+		config.Taxonomy.IndexTemplate = filepath.Join(config.TemplatesPath, config.Taxonomy.IndexTemplate)
+	}
+	if len(config.Taxonomy.TagTemplate) > 0 {
+		config.Taxonomy.TagTemplate = filepath.Join(config.TemplatesPath, config.Taxonomy.TagTemplate)
+	}
 
 	// Build the path trie for content entries
 	contentTrie := newTrie()
@@ -145,7 +289,10 @@ func ParseConfig(configPath string) (Config, error) {
 		if config.Content[i].OutputPath != "" {
 			config.Content[i].OutputPath = filepath.Join(config.BuildPath, config.Content[i].OutputPath)
 		}
-		config.Content[i].Template = filepath.Join(config.TemplatesPath, config.Content[i].Template)
+		// Entries without a template keep the one they cascade from
+		if config.Content[i].Template != "" {
+			config.Content[i].Template = filepath.Join(config.TemplatesPath, config.Content[i].Template)
+		}
 		contentTrie.insert(config.Content[i].InputPath, &config.Content[i])
 	}
 	config.contentTrie = *contentTrie
@@ -153,32 +300,71 @@ func ParseConfig(configPath string) (Config, error) {
 	return config, nil
 }
 
+// NewConverterRegistry returns the registered converters with the extension mappings from config applied.
+func NewConverterRegistry(config Config) (*converters.Registry, error) {
+	registry := converters.NewRegistry()
+
+	switch config.MarkdownRenderer {
+	case "", "native":
+		// markdown is the default converter for .md
+	case "pandoc":
+		if err := registry.Map(".md", "pandoc"); err != nil {
+			return nil, err
+		}
+	default:
+		err := fmt.Errorf("unknown markdown renderer %q, expected native or pandoc", config.MarkdownRenderer)
+		log.Error().Err(err).Msg("Invalid markdown_renderer in config.")
+		return nil, err
+	}
+
+	for extension, name := range config.ConverterExtensions {
+		if err := registry.Map(extension, name); err != nil {
+			log.Error().Err(err).Str("extension", extension).Str("converter", name).Msg("Failed to map extension onto converter.")
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// MatchContentEntry works out the entry of the page for inputPath. Values cascade from the
+// config defaults over the content entries of the directories containing the file, the most
+// specific last, to the file's front matter. Later values win, except that tags are unioned
+// and metadata is merged key by key.
 func MatchContentEntry(config Config, inputPath string, parseFrontMatter bool) ContentEntry {
-	matchedEntry := config.contentTrie.search(inputPath)
-	retEntry := ContentEntry{}
-	if matchedEntry == nil && config.DefaultTemplate != "" {
-		// Create a default ContentEntry
-		outputPath := filepath.Join(config.BuildPath, getOutputPath(inputPath, config.ContentPath))
-		retEntry = ContentEntry{
-			InputPath:  inputPath,
-			OutputPath: outputPath,
-			Template:   config.DefaultTemplate,
+	retEntry := ContentEntry{
+		InputPath: inputPath,
+		Template:  config.DefaultTemplate,
+	}
+
+	// The most specific entry with an output path decides where the page goes
+	var mounted *ContentEntry
+	for _, matchedEntry := range config.contentTrie.searchAll(inputPath) {
+		log.Trace().Str("inputPath", inputPath).Str("entry", matchedEntry.InputPath).Msg("Matched content entry.")
+		entry := *matchedEntry
+		// Aliases name the URLs of one page and a paginated list belongs to one page, the pages
+		// below a directory can't share them
+		if entry.InputPath != inputPath {
+			entry.Aliases = nil
+			entry.Paginate = nil
 		}
-		log.Trace().Any("generatedEntry", matchedEntry).Str("outputPath", outputPath).Str("buildPath", config.BuildPath).Msg("Generated default content entry.")
-	} else if matchedEntry != nil {
-		retEntry = *matchedEntry
-		retEntry.InputPath = inputPath
-		resolvedOutputPath := filepath.Join(config.BuildPath, getOutputPath(inputPath, config.ContentPath))
-		if retEntry.OutputPath == "" {
-			// Fill the output path using default logic
-			retEntry.OutputPath = resolvedOutputPath
-		} else if retEntry.OutputPath == resolvedOutputPath {
-			// no-op
-		} else {
-			log.Fatal().Any("entry", matchedEntry).Str("inputPath", inputPath).Msg("Remapping the output path isn't supported yet. Output path must be empty for a content entry that applies to multiple children.")
+		retEntry.merge(entry)
+		if matchedEntry.OutputPath != "" {
+			mounted = matchedEntry
 		}
 	}
 
+	switch {
+	case mounted == nil:
+		// Fill the output path using default logic
+		retEntry.OutputPath = filepath.Join(config.BuildPath, getOutputPath(inputPath, config.ContentPath, retEntry.usePrettyUrls(config)))
+	case mounted.InputPath == inputPath:
+		retEntry.OutputPath = mounted.OutputPath
+	default:
+		// The entry is a directory mounted at its output path, children keep their layout below it
+		retEntry.OutputPath = filepath.Join(mounted.OutputPath, getOutputPath(inputPath, mounted.InputPath, retEntry.usePrettyUrls(config)))
+		log.Trace().Str("inputPath", inputPath).Str("outputPath", retEntry.OutputPath).Msg("Remapped output path.")
+	}
+
 	if parseFrontMatter {
 		var fme FrontMatterEntry
 
@@ -191,11 +377,18 @@ func MatchContentEntry(config Config, inputPath string, parseFrontMatter bool) C
 			if err != nil {
 				log.Err(err).Str("file", inputPath).Msg("Failed to read front matter from file.")
 			} else {
-				retEntry.Title = fme.Title
-				retEntry.Description = fme.Description
-				retEntry.CreatedAt = fme.CreatedAt
-				retEntry.Tags = fme.Tags
-				retEntry.Metadata = fme.Metadata
+				retEntry.merge(ContentEntry{
+					Title:          fme.Title,
+					Description:    fme.Description,
+					CreatedAt:      fme.CreatedAt,
+					Tags:           fme.Tags,
+					Metadata:       fme.Metadata,
+					SitemapExclude: fme.SitemapExclude,
+					Paginate:       fme.Paginate,
+					Aliases:        fme.Aliases,
+					Draft:          fme.Draft,
+					PublishAt:      fme.PublishAt,
+				})
 			}
 		}
 	}
@@ -203,7 +396,83 @@ func MatchContentEntry(config Config, inputPath string, parseFrontMatter bool) C
 	return retEntry
 }
 
-func getOutputPath(inputPath string, baseInputPath string) string {
+// merge lays the values set in other over e. Paths are left alone, MatchContentEntry works
+// those out.
+func (e *ContentEntry) merge(other ContentEntry) {
+	if other.Template != "" {
+		e.Template = other.Template
+	}
+	if other.Title != "" {
+		e.Title = other.Title
+	}
+	if other.Description != "" {
+		e.Description = other.Description
+	}
+	if !other.CreatedAt.IsZero() {
+		e.CreatedAt = other.CreatedAt
+	}
+	e.Tags = unionTags(e.Tags, other.Tags)
+	if len(other.Metadata) > 0 {
+		merged := make(map[string]interface{}, len(e.Metadata)+len(other.Metadata))
+		for k, v := range e.Metadata {
+			merged[k] = v
+		}
+		for k, v := range normalizeMetadata(other.Metadata) {
+			merged[k] = v
+		}
+		e.Metadata = merged
+	}
+	if other.SitemapExclude != nil {
+		e.SitemapExclude = other.SitemapExclude
+	}
+	if other.Paginate != nil {
+		e.Paginate = other.Paginate
+	}
+	if len(other.Aliases) > 0 {
+		e.Aliases = append(append([]string{}, e.Aliases...), other.Aliases...)
+	}
+	if other.Draft != nil {
+		e.Draft = other.Draft
+	}
+	if !other.PublishAt.IsZero() {
+		e.PublishAt = other.PublishAt
+	}
+	if other.PrettyUrls != nil {
+		e.PrettyUrls = other.PrettyUrls
+	}
+}
+
+// unionTags appends the tags of b missing from a, keeping the order they were first seen in.
+func unionTags(a []string, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[string]bool, len(a)+len(b))
+	union := make([]string, 0, len(a)+len(b))
+	for _, tag := range append(append([]string{}, a...), b...) {
+		if !seen[tag] {
+			seen[tag] = true
+			union = append(union, tag)
+		}
+	}
+	return union
+}
+
+// applyDocumentMetadata merges the metadata a document carries itself into entry, like
+// front matter.
+func applyDocumentMetadata(entry *ContentEntry, metadata converters.DocumentMetadata) {
+	entry.merge(ContentEntry{
+		Title:       metadata.Title,
+		Description: metadata.Description,
+		CreatedAt:   metadata.CreatedAt,
+		Tags:        metadata.Tags,
+		Metadata:    metadata.Metadata,
+	})
+}
+
+// getOutputPath returns the path of the page for inputPath relative to the build path. With
+// pretty URLs every page but an index gets its own directory, e.g. foo/bar/index.html.
+func getOutputPath(inputPath string, baseInputPath string, prettyUrls bool) string {
 	// Get the relative path of the input file
 	relInputPath, err := filepath.Rel(baseInputPath, inputPath)
 	if err != nil {
@@ -214,5 +483,33 @@ func getOutputPath(inputPath string, baseInputPath string) string {
 	// Modify input path to have .html extension
 	ext := filepath.Ext(relInputPath)
 	base := strings.TrimSuffix(relInputPath, ext)
+	if prettyUrls && filepath.Base(base) != "index" {
+		return filepath.Join(base, "index.html")
+	}
 	return base + ".html"
 }
+
+// isPublished reports whether pages of the entry are part of a build made at now. Drafts
+// and pages scheduled for later only are when the config asks for them.
+func (e ContentEntry) isPublished(config Config, now time.Time) bool {
+	if isTrue(e.Draft) && !config.Drafts {
+		return false
+	}
+	if e.PublishAt.After(now) && !config.Future {
+		return false
+	}
+	return true
+}
+
+// usePrettyUrls reports whether pages of the entry are written as foo/bar/index.html.
+func (e ContentEntry) usePrettyUrls(config Config) bool {
+	if e.PrettyUrls != nil {
+		return *e.PrettyUrls
+	}
+	return config.PrettyUrls
+}
+
+// isTrue reports whether an optional flag is set and true.
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
@@ -0,0 +1,126 @@
+package application
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v2"
+)
+
+// LoadData parses the YAML, JSON, TOML and CSV files below the data directory into nested
+// maps keyed by directory and file name without extension, e.g. data/team/members.yaml
+// becomes .Site.Data.team.members. CSV files are lists of rows. The returned hash changes
+// whenever any data file does.
+func LoadData(config Config) (map[string]interface{}, string, error) {
+	if config.DataPath == "" {
+		return nil, "", nil
+	}
+	if !fileExists(config.DataPath) {
+		log.Debug().Str("dataPath", config.DataPath).Msg("Data directory doesn't exist, skipping data.")
+		return nil, "", nil
+	}
+
+	var paths []string
+	err := filepath.Walk(config.DataPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !strings.HasPrefix(info.Name(), ".") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Str("dataPath", config.DataPath).Msg("Failed to collect data files.")
+		return nil, "", err
+	}
+	sort.Strings(paths)
+
+	data := make(map[string]interface{})
+	var hashes bytes.Buffer
+	for _, path := range paths {
+		rel, err := filepath.Rel(config.DataPath, path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			log.Error().Err(err).Str("file", path).Msg("Failed to read data file.")
+			return nil, "", &FileError{Path: path, Err: err}
+		}
+		value, ok, err := parseDataFile(path, contents)
+		if err != nil {
+			log.Error().Err(err).Str("file", path).Msg("Failed to parse data file.")
+			return nil, "", &FileError{Path: path, Err: err}
+		}
+		if !ok {
+			log.Debug().Str("file", path).Msg("Skipping data file of unknown type.")
+			continue
+		}
+		fmt.Fprintf(&hashes, "%s %s\n", filepath.ToSlash(rel), hashBytes(contents))
+
+		// Walk down to the map of the file's directory, creating maps along the way
+		keys := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))), "/")
+		parent := data
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := parent[key].(map[string]interface{})
+			if !ok {
+				if _, exists := parent[key]; exists {
+					return nil, "", &FileError{Path: path, Err: fmt.Errorf("data directory %q has the same name as a data file", key)}
+				}
+				child = make(map[string]interface{})
+				parent[key] = child
+			}
+			parent = child
+		}
+		name := keys[len(keys)-1]
+		if _, exists := parent[name]; exists {
+			return nil, "", &FileError{Path: path, Err: fmt.Errorf("data key %q is already used by another file or directory", name)}
+		}
+		parent[name] = value
+	}
+
+	return data, hashBytes(hashes.Bytes()), nil
+}
+
+// parseDataFile decodes a data file by its extension. It returns false for files that aren't
+// data files.
+func parseDataFile(path string, contents []byte) (interface{}, bool, error) {
+	var value interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(contents, &value); err != nil {
+			return nil, true, err
+		}
+	case ".json":
+		if err := json.Unmarshal(contents, &value); err != nil {
+			return nil, true, err
+		}
+	case ".toml":
+		var m map[string]interface{}
+		if err := toml.Unmarshal(contents, &m); err != nil {
+			return nil, true, err
+		}
+		value = m
+	case ".csv":
+		reader := csv.NewReader(bytes.NewReader(contents))
+		reader.FieldsPerRecord = -1
+		rows, err := reader.ReadAll()
+		if err != nil {
+			return nil, true, err
+		}
+		return rows, true, nil
+	default:
+		return nil, false, nil
+	}
+	return normalizeMetadataValue(value), true, nil
+}
@@ -0,0 +1,73 @@
+package application
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+
+	"main/internal/converters"
+)
+
+// FileError attributes a build error to the file that caused it.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// BuildProblem is a single build error prepared for display in the browser.
+type BuildProblem struct {
+	File    string
+	Line    int
+	Message string
+	Output  string
+}
+
+// templateErrorPattern matches the errors of html/template, e.g.
+// `template: main.html:12:5: executing "main.html" at <.Foo>: can't evaluate field Foo`.
+var templateErrorPattern = regexp.MustCompile(`^template: ([^:]+):(\d+)(?::\d+)?: (.*)$`)
+
+// DescribeBuildError splits a (possibly joined) build error into problems to display.
+func DescribeBuildError(err error) []BuildProblem {
+	if err == nil {
+		return nil
+	}
+
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var problems []BuildProblem
+		for _, e := range joined.Unwrap() {
+			problems = append(problems, DescribeBuildError(e)...)
+		}
+		return problems
+	}
+
+	problem := BuildProblem{Message: err.Error()}
+
+	var fileErr *FileError
+	if errors.As(err, &fileErr) {
+		problem.File = fileErr.Path
+		problem.Message = fileErr.Err.Error()
+	}
+
+	// The line belongs to the template named in the message, which may be a partial included
+	// by the page's template
+	if m := templateErrorPattern.FindStringSubmatch(problem.Message); m != nil {
+		problem.File = m[1]
+		problem.Line, _ = strconv.Atoi(m[2])
+		problem.Message = m[3]
+	}
+
+	var conversionErr *converters.ConversionError
+	if errors.As(err, &conversionErr) {
+		problem.Output = conversionErr.Output
+	}
+
+	return []BuildProblem{problem}
+}
@@ -0,0 +1,412 @@
+package application
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+)
+
+const (
+	feedFormatRss  = "rss"
+	feedFormatAtom = "atom"
+	feedFormatJson = "json"
+)
+
+// feedFileNames maps each feed format onto the file it's written to in the feed's output path.
+var feedFileNames = map[string]string{
+	feedFormatRss:  "rss.xml",
+	feedFormatAtom: "atom.xml",
+	feedFormatJson: "feed.json",
+}
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNs    string     `xml:"xmlns:atom,attr"`
+	ContentNs string     `xml:"xmlns:content,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Guid        rssGuid    `xml:"guid"`
+	PubDate     string     `xml:"pubDate,omitempty"`
+	Description string     `xml:"description,omitempty"`
+	Content     rssContent `xml:"content:encoded"`
+	Categories  []string   `xml:"category"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssContent struct {
+	Value string `xml:",cdata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Id         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    atomContent    `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageUrl string         `json:"home_page_url,omitempty"`
+	FeedUrl     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	Id            string   `json:"id"`
+	Url           string   `json:"url"`
+	Title         string   `json:"title,omitempty"`
+	ContentHtml   string   `json:"content_html"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// feedPages selects the newest pages matching the feed's filters. Pages listing other pages,
+// like the index of the prefix, aren't items themselves. URLs in the contents of the items are
+// made absolute, feed readers don't know where the pages live.
+func feedPages(config Config, feed FeedEntry, pages []TPage) []TPage {
+	var list TPageList
+	for _, p := range pages {
+		if p.Paginated || (feed.UrlPrefix != "" && p.UrlPath != "" && redirectKey(p.UrlPath) == redirectKey(feed.UrlPrefix)) {
+			continue
+		}
+		list = append(list, p)
+	}
+	if feed.UrlPrefix != "" {
+		list = list.FilterByUrlPathPrefix(feed.UrlPrefix)
+	}
+	if feed.Tag != "" {
+		list = list.FilterByTag(feed.Tag)
+	}
+
+	// Sorting by date can't fail
+	list, _ = list.SortBy("date", "desc")
+	if feed.Limit > 0 {
+		list = list.Limit(feed.Limit)
+	}
+
+	items := make([]TPage, len(list))
+	for i, p := range list {
+		items[i] = p
+		if p.UrlPath != "" && p.Contents != "" {
+			items[i].Contents = absoluteUrls(config.BaseUrl+p.UrlPath, p.Contents)
+		}
+	}
+	return items
+}
+
+// feedUrlAttributes are the attributes holding URLs that are resolved in feed contents.
+var feedUrlAttributes = map[string]bool{"href": true, "src": true, "poster": true}
+
+// absoluteUrls resolves the relative URLs of links, images and media in contents against
+// pageUrl, so _assets/a.png on https://example.com/blog/post/ becomes
+// https://example.com/blog/post/_assets/a.png. Everything else is copied as is.
+func absoluteUrls(pageUrl string, contents template.HTML) template.HTML {
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return contents
+	}
+
+	var b strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(string(contents)))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			return template.HTML(b.String())
+		}
+		raw := tokenizer.Raw()
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			b.Write(raw)
+			continue
+		}
+
+		token := tokenizer.Token()
+		changed := false
+		for i, attr := range token.Attr {
+			switch {
+			case feedUrlAttributes[attr.Key]:
+				if resolved := resolveUrl(base, attr.Val); resolved != attr.Val {
+					token.Attr[i].Val = resolved
+					changed = true
+				}
+			case attr.Key == "srcset":
+				candidates := strings.Split(attr.Val, ",")
+				for j, candidate := range candidates {
+					fields := strings.Fields(candidate)
+					if len(fields) > 0 {
+						fields[0] = resolveUrl(base, fields[0])
+						candidates[j] = strings.Join(fields, " ")
+					}
+				}
+				if resolved := strings.Join(candidates, ", "); resolved != attr.Val {
+					token.Attr[i].Val = resolved
+					changed = true
+				}
+			}
+		}
+		if changed {
+			b.WriteString(token.String())
+		} else {
+			b.Write(raw)
+		}
+	}
+}
+
+// resolveUrl resolves ref against base, leaving absolute URLs and fragments alone.
+func resolveUrl(base *url.URL, ref string) string {
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.IsAbs() {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// GenerateFeeds writes every feed configured in config.Feeds into the build directory. now is
+// the time of the build, which Atom feeds fall back to for their required update times.
+func GenerateFeeds(config Config, pages []TPage, now time.Time, next *Manifest) error {
+	if len(config.Feeds) == 0 {
+		return nil
+	}
+	if config.BaseUrl == "" {
+		err := errors.New("base_url is required to generate feeds")
+		log.Error().Err(err).Msg("Failed to generate feeds.")
+		return err
+	}
+
+	for _, feed := range config.Feeds {
+		if feed.Title == "" {
+			feed.Title = config.SiteTitle
+		}
+		if feed.Description == "" {
+			feed.Description = config.SiteDescription
+		}
+		formats := feed.Formats
+		if len(formats) == 0 {
+			formats = []string{feedFormatRss, feedFormatAtom, feedFormatJson}
+		}
+
+		items := feedPages(config, feed, pages)
+		for _, format := range formats {
+			fileName, ok := feedFileNames[format]
+			if !ok {
+				err := fmt.Errorf("unknown feed format %q, expected rss, atom or json", format)
+				log.Error().Err(err).Str("feed", feed.OutputPath).Msg("Failed to generate feed.")
+				return err
+			}
+
+			relPath := filepath.Join(feed.OutputPath, fileName)
+			data, err := renderFeed(config, feed, format, path.Join("/", filepath.ToSlash(relPath)), items, now)
+			if err != nil {
+				log.Error().Err(err).Str("feed", relPath).Msg("Failed to render feed.")
+				return err
+			}
+			if err := writeGeneratedOutput(config, next, relPath, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func renderFeed(config Config, feed FeedEntry, format string, feedUrlPath string, pages []TPage, now time.Time) ([]byte, error) {
+	homePageUrl := config.BaseUrl + "/"
+	if feed.UrlPrefix != "" {
+		homePageUrl = config.BaseUrl + feed.UrlPrefix
+	}
+	feedUrl := config.BaseUrl + feedUrlPath
+
+	var updated time.Time
+	for _, p := range pages {
+		if p.CreatedAt.After(updated) {
+			updated = p.CreatedAt
+		}
+	}
+
+	switch format {
+	case feedFormatRss:
+		rss := rssFeed{
+			Version:   "2.0",
+			AtomNs:    "http://www.w3.org/2005/Atom",
+			ContentNs: "http://purl.org/rss/1.0/modules/content/",
+			Channel: rssChannel{
+				Title:       feed.Title,
+				Link:        homePageUrl,
+				Description: feed.Description,
+				SelfLink:    atomLink{Href: feedUrl, Rel: "self", Type: "application/rss+xml"},
+			},
+		}
+		if !updated.IsZero() {
+			rss.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+		}
+		for _, p := range pages {
+			item := rssItem{
+				Title:       p.Title,
+				Link:        feedItemUrl(config, p),
+				Guid:        rssGuid{IsPermaLink: true, Value: feedItemUrl(config, p)},
+				Description: p.Description,
+				Content:     rssContent{Value: string(p.Contents)},
+				Categories:  p.Tags,
+			}
+			if !p.CreatedAt.IsZero() {
+				item.PubDate = p.CreatedAt.Format(time.RFC1123Z)
+			}
+			rss.Channel.Items = append(rss.Channel.Items, item)
+		}
+		return marshalXml(rss)
+
+	case feedFormatAtom:
+		atom := atomFeed{
+			Title: feed.Title,
+			Id:    feedUrl,
+			Links: []atomLink{
+				{Href: feedUrl, Rel: "self", Type: "application/atom+xml"},
+				{Href: homePageUrl, Rel: "alternate", Type: "text/html"},
+			},
+		}
+		// Atom requires update times, an empty feed was last updated by this build
+		var atomUpdated time.Time
+		for _, p := range pages {
+			entryUpdated := atomEntryUpdated(p, now)
+			if entryUpdated.After(atomUpdated) {
+				atomUpdated = entryUpdated
+			}
+			entry := atomEntry{
+				Title:   p.Title,
+				Id:      feedItemUrl(config, p),
+				Link:    atomLink{Href: feedItemUrl(config, p), Rel: "alternate", Type: "text/html"},
+				Updated: entryUpdated.Format(time.RFC3339),
+				Summary: p.Description,
+				Content: atomContent{Type: "html", Value: string(p.Contents)},
+			}
+			if !p.CreatedAt.IsZero() {
+				entry.Published = p.CreatedAt.Format(time.RFC3339)
+			}
+			for _, tag := range p.Tags {
+				entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+			}
+			atom.Entries = append(atom.Entries, entry)
+		}
+		if atomUpdated.IsZero() {
+			atomUpdated = now
+		}
+		atom.Updated = atomUpdated.Format(time.RFC3339)
+		return marshalXml(atom)
+
+	case feedFormatJson:
+		jf := jsonFeed{
+			Version:     "https://jsonfeed.org/version/1.1",
+			Title:       feed.Title,
+			HomePageUrl: homePageUrl,
+			FeedUrl:     feedUrl,
+			Description: feed.Description,
+			Items:       make([]jsonFeedItem, 0, len(pages)),
+		}
+		for _, p := range pages {
+			item := jsonFeedItem{
+				Id:          feedItemUrl(config, p),
+				Url:         feedItemUrl(config, p),
+				Title:       p.Title,
+				ContentHtml: string(p.Contents),
+				Summary:     p.Description,
+				Tags:        p.Tags,
+			}
+			if !p.CreatedAt.IsZero() {
+				item.DatePublished = p.CreatedAt.Format(time.RFC3339)
+			}
+			jf.Items = append(jf.Items, item)
+		}
+		return json.MarshalIndent(jf, "", "  ")
+	}
+
+	return nil, fmt.Errorf("unknown feed format %q", format)
+}
+
+// atomEntryUpdated returns when the page was last updated, which is when it was created, the
+// modification time of its source when that's unknown, or the build time as a last resort.
+func atomEntryUpdated(p TPage, now time.Time) time.Time {
+	if !p.CreatedAt.IsZero() {
+		return p.CreatedAt
+	}
+	if modTime := GetCreationTimeForFile(p.SourcePath); !modTime.IsZero() {
+		return modTime
+	}
+	return now
+}
+
+// feedItemUrl links bookmarks straight to the bookmarked page, like link blogs do.
+func feedItemUrl(config Config, p TPage) string {
+	if p.Kind == PageKindLink {
+		return p.Link
+	}
+	return config.BaseUrl + p.UrlPath
+}
+
+func marshalXml(v interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
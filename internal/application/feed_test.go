@@ -0,0 +1,74 @@
+package application
+
+import (
+	"html/template"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAbsoluteUrls(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`<img src="_assets/a.png">`, `<img src="https://example.com/blog/post/_assets/a.png">`},
+		{`<a href="../other/">x</a>`, `<a href="https://example.com/blog/other/">x</a>`},
+		{`<a href="/about/">x</a>`, `<a href="https://example.com/about/">x</a>`},
+		{`<a href="https://other.org/">x</a>`, `<a href="https://other.org/">x</a>`},
+		{`<a href="#notes">x</a>`, `<a href="#notes">x</a>`},
+		{`<a href="mailto:a@example.com">x</a>`, `<a href="mailto:a@example.com">x</a>`},
+		{`<img src="data:image/png;base64,AAAA">`, `<img src="data:image/png;base64,AAAA">`},
+		{`<img srcset="a.png 1x, /b.png 2x">`, `<img srcset="https://example.com/blog/post/a.png 1x, https://example.com/b.png 2x">`},
+		{`<p class="x">Text &amp; <em>more</em></p>`, `<p class="x">Text &amp; <em>more</em></p>`},
+	}
+	for _, tt := range tests {
+		if got := absoluteUrls("https://example.com/blog/post/", template.HTML(tt.in)); string(got) != tt.want {
+			t.Errorf("absoluteUrls(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFeedPages(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	pages := []TPage{
+		{Title: "Blog", UrlPath: "/blog/", CreatedAt: day(9)},
+		{Title: "Archive", UrlPath: "/blog/archive/", CreatedAt: day(8), Paginated: true},
+		{Title: "First", UrlPath: "/blog/first/", CreatedAt: day(1), Contents: `<img src="_assets/a.png">`},
+		{Title: "Second", UrlPath: "/blog/second/", CreatedAt: day(2), Tags: []string{"go"}},
+		{Title: "About", UrlPath: "/about/", CreatedAt: day(3)},
+	}
+	config := Config{BaseUrl: "https://example.com"}
+
+	tests := []struct {
+		feed FeedEntry
+		want []string
+	}{
+		{FeedEntry{UrlPrefix: "/blog/"}, []string{"Second", "First"}},
+		{FeedEntry{UrlPrefix: "/blog"}, []string{"Second", "First"}},
+		{FeedEntry{}, []string{"Blog", "About", "Second", "First"}},
+		{FeedEntry{UrlPrefix: "/blog/", Tag: "go"}, []string{"Second"}},
+		{FeedEntry{UrlPrefix: "/blog/", Limit: 1}, []string{"Second"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, p := range feedPages(config, tt.feed, pages) {
+			got = append(got, p.Title)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("feedPages(%+v) = %v, want %v", tt.feed, got, tt.want)
+		}
+	}
+
+	items := feedPages(config, FeedEntry{UrlPrefix: "/blog/first/"}, pages)
+	if len(items) != 0 {
+		t.Errorf("feedPages with the prefix of a page = %d items, want none", len(items))
+	}
+	items = feedPages(config, FeedEntry{Tag: ""}, pages)
+	if want := template.HTML(`<img src="https://example.com/blog/first/_assets/a.png">`); items[3].Contents != want {
+		t.Errorf("Contents = %q, want %q", items[3].Contents, want)
+	}
+	if pages[2].Contents != `<img src="_assets/a.png">` {
+		t.Errorf("feedPages changed the contents of its input to %q", pages[2].Contents)
+	}
+}
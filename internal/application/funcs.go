@@ -0,0 +1,569 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"main/internal/converters"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+)
+
+// templateFuncs returns the functions available in every template. Functions taking a string
+// take it last, so they can be used in pipelines like {{ .Title | truncate 20 }}. Files read by
+// templates are recorded in reads.
+func templateFuncs(config Config, reads *fileReads) template.FuncMap {
+	return template.FuncMap{
+		"HasPrefix": hasPrefix,
+
+		// Strings
+		"lower": func(s interface{}) string { return strings.ToLower(metadataString(s)) },
+		"upper": func(s interface{}) string { return strings.ToUpper(metadataString(s)) },
+		"trim":  func(s interface{}) string { return strings.TrimSpace(metadataString(s)) },
+		"replace": func(old string, new string, s interface{}) string {
+			return strings.ReplaceAll(metadataString(s), old, new)
+		},
+		"split":       func(sep string, s interface{}) []string { return strings.Split(metadataString(s), sep) },
+		"join":        join,
+		"slugify":     func(s interface{}) string { return Slugify(metadataString(s)) },
+		"truncate":    truncate,
+		"plainify":    plainify,
+		"markdownify": markdownify,
+
+		// Dates
+		"now":        time.Now,
+		"dateFormat": dateFormat,
+		"timeAgo":    timeAgo,
+
+		// Math
+		"add": func(a interface{}, b interface{}) (interface{}, error) { return arithmetic("add", a, b) },
+		"sub": func(a interface{}, b interface{}) (interface{}, error) { return arithmetic("sub", a, b) },
+		"mul": func(a interface{}, b interface{}) (interface{}, error) { return arithmetic("mul", a, b) },
+		"div": func(a interface{}, b interface{}) (interface{}, error) { return arithmetic("div", a, b) },
+		"mod": func(a interface{}, b interface{}) (interface{}, error) { return arithmetic("mod", a, b) },
+		"min": func(a interface{}, b interface{}) (interface{}, error) { return arithmetic("min", a, b) },
+		"max": func(a interface{}, b interface{}) (interface{}, error) { return arithmetic("max", a, b) },
+
+		// Collections
+		"dict":   dict,
+		"list":   func(items ...interface{}) []interface{} { return items },
+		"where":  where,
+		"sortBy": sortBy,
+		"uniq":   uniq,
+
+		// URLs
+		"absURL": func(p interface{}) string { return absURL(config, metadataString(p)) },
+		"relURL": func(p interface{}) string { return relURL(config, metadataString(p)) },
+
+		// Marking trusted values as safe so they aren't escaped
+		"safeHTML":     func(s interface{}) template.HTML { return template.HTML(metadataString(s)) },
+		"safeHTMLAttr": func(s interface{}) template.HTMLAttr { return template.HTMLAttr(metadataString(s)) },
+		"safeCSS":      func(s interface{}) template.CSS { return template.CSS(metadataString(s)) },
+		"safeJS":       func(s interface{}) template.JS { return template.JS(metadataString(s)) },
+		"safeURL":      func(s interface{}) template.URL { return template.URL(metadataString(s)) },
+
+		"jsonify":  jsonify,
+		"readFile": func(path string) (string, error) { return readFile(config, reads, path) },
+	}
+}
+
+func hasPrefix(prefix string, s string) bool {
+	log.Logger.Trace().Str("prefix", prefix).Str("string", s).Msg("Called HasPrefix.")
+	return strings.HasPrefix(s, prefix)
+}
+
+// join joins the items of a list, e.g. {{ join ", " .Page.Tags }}.
+func join(sep string, items interface{}) (string, error) {
+	rv := reflect.ValueOf(items)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("join expects a list, got %T", items)
+	}
+	parts := make([]string, rv.Len())
+	for i := range parts {
+		parts[i] = metadataString(rv.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// truncate shortens s to at most n characters, cutting at the last word that fits and adding
+// an ellipsis when anything was cut.
+func truncate(n int, s interface{}) string {
+	if n < 0 {
+		n = 0
+	}
+	text := strings.TrimSpace(metadataString(s))
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:n])
+	if i := strings.LastIndexAny(cut, " \t\n"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
+
+// plainify strips the tags of an HTML string, e.g. to use a page's contents as a summary.
+func plainify(s interface{}) string {
+	var b strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(metadataString(s)))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.TextToken:
+			b.Write(tokenizer.Text())
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			// Keep words of adjacent block elements apart
+			b.WriteByte(' ')
+		}
+	}
+}
+
+// markdownify renders markdown, leaving out the paragraph around a lone paragraph so snippets
+// can be used inline like {{ .Page.Description | markdownify }}.
+func markdownify(s interface{}) (template.HTML, error) {
+	rendered, err := converters.RenderMarkdown([]byte(metadataString(s)))
+	if err != nil {
+		return "", err
+	}
+	rendered = bytes.TrimSpace(rendered)
+	if bytes.HasPrefix(rendered, []byte("<p>")) && bytes.HasSuffix(rendered, []byte("</p>")) && bytes.Count(rendered, []byte("<p>")) == 1 {
+		rendered = rendered[len("<p>") : len(rendered)-len("</p>")]
+	}
+	return template.HTML(rendered), nil
+}
+
+// dateFormat formats a time or a date string with a Go layout, e.g.
+// {{ .Page.CreatedAt | dateFormat "Jan 2, 2006" }}. Zero times are formatted as nothing.
+func dateFormat(layout string, t interface{}) (string, error) {
+	tm, ok := metadataTime(t)
+	if !ok {
+		return "", fmt.Errorf("dateFormat expects a date, got %T", t)
+	}
+	if tm.IsZero() {
+		return "", nil
+	}
+	return tm.Format(layout), nil
+}
+
+// timeAgo describes a time relative to the build, like "3 days ago" or "in 2 hours".
+func timeAgo(t interface{}) (string, error) {
+	tm, ok := metadataTime(t)
+	if !ok {
+		return "", fmt.Errorf("timeAgo expects a date, got %T", t)
+	}
+	d := time.Since(tm)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	if d < time.Minute {
+		return "just now", nil
+	}
+
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, unit := range units {
+		if d < unit.size {
+			continue
+		}
+		n := int(d / unit.size)
+		text := fmt.Sprintf("%d %s", n, unit.name)
+		if n != 1 {
+			text += "s"
+		}
+		if future {
+			return "in " + text, nil
+		}
+		return text + " ago", nil
+	}
+	return "just now", nil
+}
+
+// arithmetic applies op to two numbers, which can be written as strings too. The result is an
+// int when both are integers, so {{ div 7 2 }} is 3 and {{ div 7.0 2 }} is 3.5.
+func arithmetic(op string, a interface{}, b interface{}) (interface{}, error) {
+	x, okA := metadataNumber(a)
+	y, okB := metadataNumber(b)
+	if !okA || !okB {
+		return nil, fmt.Errorf("%s expects numbers, got %T and %T", op, a, b)
+	}
+
+	// Integers don't go through float64, which only holds them exactly up to 2^53
+	i, okA := integerValue(a)
+	j, okB := integerValue(b)
+	if okA && okB {
+		switch op {
+		case "add":
+			if (j > 0 && i > math.MaxInt-j) || (j < 0 && i < math.MinInt-j) {
+				return nil, fmt.Errorf("%s of %d and %d overflows", op, i, j)
+			}
+			return i + j, nil
+		case "sub":
+			if (j < 0 && i > math.MaxInt+j) || (j > 0 && i < math.MinInt+j) {
+				return nil, fmt.Errorf("%s of %d and %d overflows", op, i, j)
+			}
+			return i - j, nil
+		case "mul":
+			if i != 0 && ((i*j)/i != j || (i == -1 && j == math.MinInt)) {
+				return nil, fmt.Errorf("%s of %d and %d overflows", op, i, j)
+			}
+			return i * j, nil
+		case "div", "mod":
+			if j == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if op == "div" {
+				if i == math.MinInt && j == -1 {
+					return nil, fmt.Errorf("%s of %d and %d overflows", op, i, j)
+				}
+				return i / j, nil
+			}
+			return i % j, nil
+		case "min":
+			if j < i {
+				return j, nil
+			}
+			return i, nil
+		case "max":
+			if j > i {
+				return j, nil
+			}
+			return i, nil
+		}
+	}
+
+	switch op {
+	case "add":
+		return x + y, nil
+	case "sub":
+		return x - y, nil
+	case "mul":
+		return x * y, nil
+	case "div", "mod":
+		if y == 0 {
+			return nil, errors.New("division by zero")
+		}
+		if op == "div" {
+			return x / y, nil
+		}
+		return math.Mod(x, y), nil
+	case "min":
+		return math.Min(x, y), nil
+	case "max":
+		return math.Max(x, y), nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op)
+}
+
+// integerValue returns value as an int when it's an integer that fits one.
+func integerValue(value interface{}) (int, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < math.MinInt || v.Int() > math.MaxInt {
+			return 0, false
+		}
+		return int(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt {
+			return 0, false
+		}
+		return int(v.Uint()), true
+	}
+	return 0, false
+}
+
+// dict builds a map from key value pairs, e.g. to pass several values to another template:
+// {{ template "card.html" dict "page" .Page "wide" true }}.
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict expects key value pairs")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings, got %T", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// lookupField reads a dotted path of fields, methods without arguments and map keys, e.g.
+// "Metadata.hero.image" of a page.
+func lookupField(item reflect.Value, path string) (interface{}, bool) {
+	v := item
+	if path != "" {
+		for _, name := range strings.Split(path, ".") {
+			for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return nil, false
+				}
+				v = v.Elem()
+			}
+
+			switch v.Kind() {
+			case reflect.Struct:
+				if f := v.FieldByName(name); f.IsValid() && f.CanInterface() {
+					v = f
+					continue
+				}
+				m := v.MethodByName(name)
+				if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+					return nil, false
+				}
+				v = m.Call(nil)[0]
+			case reflect.Map:
+				if v.Type().Key().Kind() != reflect.String {
+					return nil, false
+				}
+				f := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+				if !f.IsValid() {
+					return nil, false
+				}
+				v = f
+			default:
+				return nil, false
+			}
+		}
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+// listValue checks that collection is a list and returns it with an empty list of its type,
+// so the collection functions return page lists for page lists.
+func listValue(name string, collection interface{}) (reflect.Value, reflect.Value, error) {
+	rv := reflect.ValueOf(collection)
+	switch rv.Kind() {
+	case reflect.Slice:
+		return rv, reflect.MakeSlice(rv.Type(), 0, rv.Len()), nil
+	case reflect.Array:
+		return rv, reflect.MakeSlice(reflect.SliceOf(rv.Type().Elem()), 0, rv.Len()), nil
+	}
+	return rv, reflect.Value{}, fmt.Errorf("%s expects a list, got %T", name, collection)
+}
+
+// where keeps the items whose field compares to a value, with == when the operator is left
+// out: {{ where .Pages "Kind" "page" }} or {{ where .Pages "Metadata.weight" ">" 2 }}. The
+// operators are the ones of WhereMetadata.
+func where(collection interface{}, key string, args ...interface{}) (interface{}, error) {
+	var op string
+	var val interface{}
+	switch len(args) {
+	case 1:
+		op, val = "==", args[0]
+	case 2:
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("where expects an operator, got %T", args[0])
+		}
+		op, val = s, args[1]
+	default:
+		return nil, errors.New("where expects a key, an optional operator and a value")
+	}
+
+	rv, ret, err := listValue("where", collection)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		v, ok := lookupField(item, key)
+		if !ok {
+			continue
+		}
+		matched, err := matchMetadata(v, op, val)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			ret = reflect.Append(ret, item)
+		}
+	}
+	return ret.Interface(), nil
+}
+
+// sortBy sorts the items by a field, or by the items themselves when the key is empty, in
+// "asc" (default) or "desc" order: {{ sortBy .Pages "Metadata.weight" "desc" }}. Items
+// missing the field are sorted last.
+func sortBy(collection interface{}, key string, order ...string) (interface{}, error) {
+	desc := false
+	if len(order) > 0 {
+		switch strings.ToLower(order[0]) {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return nil, fmt.Errorf("unknown sort order %q, expected asc or desc", order[0])
+		}
+	}
+
+	rv, ret, err := listValue("sortBy", collection)
+	if err != nil {
+		return nil, err
+	}
+	ret = reflect.AppendSlice(ret, rv.Slice(0, rv.Len()))
+	sort.SliceStable(ret.Interface(), func(i, j int) bool {
+		a, okA := lookupField(ret.Index(i), key)
+		b, okB := lookupField(ret.Index(j), key)
+		if okA != okB {
+			return okA
+		}
+		if desc {
+			a, b = b, a
+		}
+		c, ok := compareMetadata(a, b)
+		return ok && c < 0
+	})
+	return ret.Interface(), nil
+}
+
+// uniq removes repeated items, keeping the first of each.
+func uniq(collection interface{}) (interface{}, error) {
+	rv, ret, err := listValue("uniq", collection)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		seen := false
+		for j := 0; j < ret.Len(); j++ {
+			if reflect.DeepEqual(ret.Index(j).Interface(), item.Interface()) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			ret = reflect.Append(ret, item)
+		}
+	}
+	return ret.Interface(), nil
+}
+
+func isAbsoluteUrl(p string) bool {
+	u, err := url.Parse(p)
+	return strings.HasPrefix(p, "//") || (err == nil && u.IsAbs())
+}
+
+// absURL prefixes a site path with base_url, falling back to relURL without one.
+func absURL(config Config, p string) string {
+	if isAbsoluteUrl(p) {
+		return p
+	}
+	return config.BaseUrl + relURL(config, p)
+}
+
+// relURL turns a path or a URL of the site into a path from the site root.
+func relURL(config Config, p string) string {
+	if config.BaseUrl != "" && strings.HasPrefix(p, config.BaseUrl) {
+		p = strings.TrimPrefix(p, config.BaseUrl)
+	} else if isAbsoluteUrl(p) {
+		return p
+	}
+	return "/" + strings.TrimPrefix(p, "/")
+}
+
+// jsonify encodes a value as JSON, which can be used as is in scripts, e.g.
+// <script type="application/ld+json">{{ jsonify .Page.Metadata }}</script>.
+func jsonify(v interface{}) (template.JS, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return template.JS(data), nil
+}
+
+// readFile reads a file relative to the directory of the config. Files outside of it can't be
+// read, also not through symlinks, so templates can't leak other files of the machine into the
+// site. The file is recorded in reads unless it's nil.
+func readFile(config Config, reads *fileReads, path string) (string, error) {
+	root := filepath.Dir(config.ConfigPath)
+	fullPath := filepath.Join(root, path)
+	if filepath.IsAbs(path) || !isInside(root, fullPath) {
+		return "", fmt.Errorf("readFile can only read files inside the site directory, got %q", path)
+	}
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	resolvedPath, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return "", err
+	}
+	if !isInside(resolvedRoot, resolvedPath) {
+		return "", fmt.Errorf("readFile can only read files inside the site directory, %q links outside of it", path)
+	}
+
+	contents, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", err
+	}
+	log.Trace().Str("file", fullPath).Msg("Read file from template.")
+	if reads != nil {
+		reads.record(fullPath, contents)
+	}
+	return string(contents), nil
+}
+
+// isInside reports whether path is root or below it.
+func isInside(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fileReads records the files read by templates and the hash of their contents, so the next
+// build can tell when the pages have to be rendered again.
+type fileReads struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+func (r *fileReads) record(path string, contents []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.hashes == nil {
+		r.hashes = make(map[string]string)
+	}
+	r.hashes[path] = hashBytes(contents)
+}
+
+// take returns the files recorded so far and starts over.
+func (r *fileReads) take() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hashes := r.hashes
+	r.hashes = nil
+	if hashes == nil {
+		hashes = make(map[string]string)
+	}
+	return hashes
+}
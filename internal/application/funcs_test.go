@@ -0,0 +1,290 @@
+package application
+
+import (
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n    int
+		s    interface{}
+		want string
+	}{
+		{10, "short", "short"},
+		{5, "exact", "exact"},
+		{12, "The quick brown fox jumps", "The quick…"},
+		{3, "Verylongword", "Ver…"},
+		{4, "Ünïcödé text", "Ünïc…"},
+		{10, "  padded  ", "padded"},
+		{11, template.HTML("<b>html</b> is text"), "<b>html</b>…"},
+		{0, "anything", "…"},
+		{-1, "anything", "…"},
+		{-1, "", ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.n, tt.s); got != tt.want {
+			t.Errorf("truncate(%d, %q) = %q, want %q", tt.n, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		op      string
+		a, b    interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"add", 1, 2, 3, false},
+		{"add", 1, 2.5, 3.5, false},
+		{"add", int64(1), uint8(2), 3, false},
+		{"add", "4", 2, 6.0, false},
+		{"sub", 2, 5, -3, false},
+		{"mul", 2, 2.5, 5.0, false},
+		{"div", 7, 2, 3, false},
+		{"div", 7.0, 2, 3.5, false},
+		{"div", 1, 0, nil, true},
+		{"div", 1.5, 0.0, nil, true},
+		{"mod", 7, 3, 1, false},
+		{"mod", 7.5, 2, 1.5, false},
+		{"mod", 7, 0, nil, true},
+		{"min", 3, 1, 1, false},
+		{"min", 3, 1.5, 1.5, false},
+		{"max", 3, 4, 4, false},
+		{"add", 1 << 53, 1, 1<<53 + 1, false},
+		{"mul", int64(3037000499), int64(3037000499), 9223372030926249001, false},
+		{"sub", uint64(1 << 62), -(1 << 62), nil, true},
+		{"add", math.MaxInt, 1, nil, true},
+		{"mul", math.MinInt, -1, nil, true},
+		{"div", math.MinInt, -1, nil, true},
+		{"div", 1<<60 + 1, 1, 1<<60 + 1, false},
+		{"mod", -7, 3, -1, false},
+		{"add", uint64(math.MaxUint64), 1, float64(math.MaxUint64) + 1, false},
+		{"add", "x", 1, nil, true},
+		{"add", true, 1, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := arithmetic(tt.op, tt.a, tt.b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("arithmetic(%q, %v, %v) error = %v, want error %v", tt.op, tt.a, tt.b, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("arithmetic(%q, %v, %v) = %#v, want %#v", tt.op, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	root := t.TempDir()
+	site := filepath.Join(root, "site")
+	if err := os.MkdirAll(filepath.Join(site, "snippets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(site, "snippets", "hello.html"), []byte("<b>hello</b>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "shared"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "shared", "footer.html"), []byte("footer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for link, target := range map[string]string{
+		filepath.Join(site, "snippets", "alias.html"): "hello.html",
+		filepath.Join(site, "snippets", "secret.txt"): filepath.Join("..", "..", "secret.txt"),
+		filepath.Join(site, "shared"):                 filepath.Join(root, "shared"),
+		filepath.Join(root, "linked-site"):            site,
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("can't create symlinks: %v", err)
+		}
+	}
+
+	tests := []struct {
+		configDir string
+		path      string
+		want      string
+		wantErr   bool
+	}{
+		{site, "snippets/hello.html", "<b>hello</b>", false},
+		{site, "snippets/../snippets/hello.html", "<b>hello</b>", false},
+		{site, "snippets/missing.html", "", true},
+		{site, "../secret.txt", "", true},
+		{site, "snippets/../../secret.txt", "", true},
+		{site, "..", "", true},
+		{site, filepath.Join(root, "secret.txt"), "", true},
+		{site, filepath.Join(site, "snippets", "hello.html"), "", true},
+		{site, "snippets/alias.html", "<b>hello</b>", false},
+		{site, "snippets/secret.txt", "", true},
+		{site, "shared/footer.html", "", true},
+		{filepath.Join(root, "linked-site"), "snippets/hello.html", "<b>hello</b>", false},
+		{filepath.Join(root, "linked-site"), "snippets/secret.txt", "", true},
+	}
+	for _, tt := range tests {
+		config := Config{ConfigPath: filepath.Join(tt.configDir, "config.yaml")}
+		got, err := readFile(config, nil, tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readFile(%q) error = %v, want error %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("readFile(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func testPages() TPageList {
+	return TPageList{
+		{Kind: PageKindPage, Title: "Bravo", UrlPath: "/blog/b/", Metadata: map[string]interface{}{"weight": 2}},
+		{Kind: PageKindLink, Title: "Alpha", Link: "https://example.com/"},
+		{Kind: PageKindPage, Title: "Charlie", UrlPath: "/blog/c/", Metadata: map[string]interface{}{"weight": 10}},
+		{Kind: PageKindPage, Title: "Delta", UrlPath: "/about/"},
+	}
+}
+
+func titles(t *testing.T, v interface{}) []string {
+	t.Helper()
+	pages, ok := v.(TPageList)
+	if !ok {
+		t.Fatalf("result is a %T, want TPageList", v)
+	}
+	var ret []string
+	for _, p := range pages {
+		ret = append(ret, p.Title)
+	}
+	return ret
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		key  string
+		args []interface{}
+		want []string
+	}{
+		{"Kind", []interface{}{"page"}, []string{"Bravo", "Charlie", "Delta"}},
+		{"Kind", []interface{}{"!=", "page"}, []string{"Alpha"}},
+		{"Metadata.weight", []interface{}{">", 2}, []string{"Charlie"}},
+		{"Metadata.weight", []interface{}{">=", "2"}, []string{"Bravo", "Charlie"}},
+		{"Section", []interface{}{"blog"}, []string{"Bravo", "Charlie"}},
+		{"Title", []interface{}{"in", []interface{}{"Alpha", "Delta"}}, []string{"Alpha", "Delta"}},
+		{"Missing", []interface{}{"x"}, nil},
+	}
+	for _, tt := range tests {
+		got, err := where(testPages(), tt.key, tt.args...)
+		if err != nil {
+			t.Errorf("where(%q, %v) error = %v", tt.key, tt.args, err)
+			continue
+		}
+		if titles := titles(t, got); !reflect.DeepEqual(titles, tt.want) {
+			t.Errorf("where(%q, %v) = %v, want %v", tt.key, tt.args, titles, tt.want)
+		}
+	}
+
+	if _, err := where(testPages(), "Title", "~", "x"); err == nil {
+		t.Error("where with an unknown operator succeeded, want an error")
+	}
+	if _, err := where("not a list", "Title", "x"); err == nil {
+		t.Error("where on a string succeeded, want an error")
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	tests := []struct {
+		key   string
+		order []string
+		want  []string
+	}{
+		{"Title", nil, []string{"Alpha", "Bravo", "Charlie", "Delta"}},
+		{"Title", []string{"desc"}, []string{"Delta", "Charlie", "Bravo", "Alpha"}},
+		// Numbers sort by value and pages without the key go last in either order
+		{"Metadata.weight", nil, []string{"Bravo", "Charlie", "Alpha", "Delta"}},
+		{"Metadata.weight", []string{"desc"}, []string{"Charlie", "Bravo", "Alpha", "Delta"}},
+	}
+	for _, tt := range tests {
+		got, err := sortBy(testPages(), tt.key, tt.order...)
+		if err != nil {
+			t.Errorf("sortBy(%q, %v) error = %v", tt.key, tt.order, err)
+			continue
+		}
+		if titles := titles(t, got); !reflect.DeepEqual(titles, tt.want) {
+			t.Errorf("sortBy(%q, %v) = %v, want %v", tt.key, tt.order, titles, tt.want)
+		}
+	}
+
+	// The input isn't reordered
+	pages := testPages()
+	if _, err := sortBy(pages, "Title"); err != nil {
+		t.Fatal(err)
+	}
+	if pages[0].Title != "Bravo" {
+		t.Errorf("sortBy reordered its input, first page is %q", pages[0].Title)
+	}
+
+	got, err := sortBy([]interface{}{3, 1, 2}, "", "desc")
+	if err != nil || !reflect.DeepEqual(got, []interface{}{3, 2, 1}) {
+		t.Errorf("sortBy of values = %v, %v, want [3 2 1]", got, err)
+	}
+	if _, err := sortBy(testPages(), "Title", "sideways"); err == nil {
+		t.Error("sortBy with an unknown order succeeded, want an error")
+	}
+}
+
+func TestUrls(t *testing.T) {
+	tests := []struct {
+		baseUrl string
+		fn      func(Config, string) string
+		name    string
+		in      string
+		want    string
+	}{
+		{"", relURL, "relURL", "about/", "/about/"},
+		{"", relURL, "relURL", "/about/", "/about/"},
+		{"", relURL, "relURL", "https://other.org/x", "https://other.org/x"},
+		{"https://example.com", relURL, "relURL", "https://example.com/blog/", "/blog/"},
+		{"https://example.com", relURL, "relURL", "https://other.org/x", "https://other.org/x"},
+		{"", absURL, "absURL", "/about/", "/about/"},
+		{"", absURL, "absURL", "about/", "/about/"},
+		{"https://example.com", absURL, "absURL", "/about/", "https://example.com/about/"},
+		{"https://example.com", absURL, "absURL", "about/", "https://example.com/about/"},
+		{"https://example.com", absURL, "absURL", "//cdn.example.org/a.js", "//cdn.example.org/a.js"},
+		{"https://example.com", absURL, "absURL", "https://other.org/x", "https://other.org/x"},
+	}
+	for _, tt := range tests {
+		if got := tt.fn(Config{BaseUrl: tt.baseUrl}, tt.in); got != tt.want {
+			t.Errorf("%s(%q) with base_url %q = %q, want %q", tt.name, tt.in, tt.baseUrl, got, tt.want)
+		}
+	}
+}
+
+func TestMarkdownify(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Some *emphasis*", "Some <em>emphasis</em>"},
+		{"", ""},
+		{"One\n\nTwo", "<p>One</p>\n<p>Two</p>"},
+		{"# Title", `<h1 id="title">Title</h1>`},
+		{"- a\n- b", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>"},
+	}
+	for _, tt := range tests {
+		got, err := markdownify(tt.in)
+		if err != nil {
+			t.Errorf("markdownify(%q) error = %v", tt.in, err)
+			continue
+		}
+		if strings.TrimSpace(string(got)) != tt.want {
+			t.Errorf("markdownify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// liveReloadPath is the Server-Sent Events endpoint browsers subscribe to.
+const liveReloadPath = "/__spot/livereload"
+
+// liveReloadScript reloads the page after a rebuild, or only swaps stylesheets when the
+// rebuild didn't change anything but static CSS files.
+const liveReloadScript = `<script>
+(function () {
+	var source = new EventSource("` + liveReloadPath + `");
+	source.addEventListener("reload", function () { location.reload(); });
+	source.addEventListener("css", function (e) {
+		var paths = JSON.parse(e.data), swapped = 0;
+		document.querySelectorAll('link[rel="stylesheet"]').forEach(function (link) {
+			var url = new URL(link.href);
+			if (url.origin === location.origin && paths.indexOf(url.pathname) >= 0) {
+				url.searchParams.set("spot-reload", Date.now());
+				link.href = url.toString();
+				swapped++;
+			}
+		});
+		if (swapped === 0) { location.reload(); }
+	});
+})();
+</script>`
+
+type liveReloadEvent struct {
+	name string
+	data string
+}
+
+// errorOverlayTemplate renders the problems of a failed rebuild on top of the stale page.
+var errorOverlayTemplate = template.Must(template.New("overlay").Parse(`<div id="spot-error-overlay" style="position:fixed;inset:0;z-index:2147483647;overflow:auto;padding:2em;background:rgba(24,24,24,0.95);color:#eee;font:14px/1.5 ui-monospace,monospace;">
+<h2 style="margin-top:0;color:#ff6b6b;">Build failed</h2>
+<p>The page underneath may be stale. This overlay goes away after the next successful build.</p>
+{{- range . }}
+<section style="margin:1.5em 0;padding:1em;border-left:4px solid #ff6b6b;background:#2b2b2b;">
+{{- if .File }}<div style="color:#ffd166;">{{ .File }}{{ if .Line }}:{{ .Line }}{{ end }}</div>{{ end }}
+<pre style="white-space:pre-wrap;margin:0.5em 0 0;">{{ .Message }}</pre>
+{{- if .Output }}<pre style="white-space:pre-wrap;margin:0.5em 0 0;color:#bbb;">{{ .Output }}</pre>{{ end }}
+</section>
+{{- end }}
+</div>`))
+
+// LiveReload notifies browsers connected to the dev server about finished rebuilds and
+// remembers why the last rebuild failed, if it did, along with the redirects of the last
+// successful build.
+type LiveReload struct {
+	mu        sync.Mutex
+	clients   map[chan liveReloadEvent]struct{}
+	done      chan struct{}
+	problems  []BuildProblem
+	redirects map[string]Redirect
+}
+
+func NewLiveReload() *LiveReload {
+	return &LiveReload{
+		clients: make(map[chan liveReloadEvent]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Notify tells connected browsers to pick up the result of a successful build.
+func (lr *LiveReload) Notify(result BuildResult) {
+	redirects := make(map[string]Redirect, len(result.Redirects))
+	for _, r := range result.Redirects {
+		redirects[redirectKey(r.From)] = r
+	}
+
+	lr.mu.Lock()
+	hadProblems := len(lr.problems) > 0
+	lr.problems = nil
+	lr.redirects = redirects
+	lr.mu.Unlock()
+
+	event := liveReloadEvent{name: "reload", data: "{}"}
+	if result.StylesheetsOnly() && !hadProblems {
+		paths := make([]string, 0, len(result.StaticChanged))
+		for _, p := range result.StaticChanged {
+			paths = append(paths, "/"+filepath.ToSlash(p))
+		}
+		data, _ := json.Marshal(paths)
+		event = liveReloadEvent{name: "css", data: string(data)}
+	}
+	lr.broadcast(event)
+}
+
+// Fail shows the error of a failed build in connected browsers until the next successful build.
+func (lr *LiveReload) Fail(err error) {
+	lr.mu.Lock()
+	lr.problems = DescribeBuildError(err)
+	lr.mu.Unlock()
+
+	lr.broadcast(liveReloadEvent{name: "reload", data: "{}"})
+}
+
+func (lr *LiveReload) currentProblems() []BuildProblem {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.problems
+}
+
+func (lr *LiveReload) lookupRedirect(urlPath string) (Redirect, bool) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	r, ok := lr.redirects[redirectKey(urlPath)]
+	return r, ok
+}
+
+func (lr *LiveReload) broadcast(event liveReloadEvent) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	log.Debug().Str("event", event.name).Int("clients", len(lr.clients)).Msg("Sending live reload event.")
+	for client := range lr.clients {
+		select {
+		case client <- event:
+		default:
+			// The browser is still busy with a previous event, it'll reload anyway
+		}
+	}
+}
+
+// Close disconnects all browsers so the server can shut down.
+func (lr *LiveReload) Close() {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	select {
+	case <-lr.done:
+	default:
+		close(lr.done)
+	}
+}
+
+func (lr *LiveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan liveReloadEvent, 1)
+	lr.mu.Lock()
+	lr.clients[client] = struct{}{}
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, client)
+		lr.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case event := <-client:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, event.data)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-lr.done:
+			return
+		}
+	}
+}
+
+// liveReloadHandler serves the build directory, injecting the live reload script into HTML
+// pages along with an overlay describing the last failed build.
+type liveReloadHandler struct {
+	root   string
+	files  http.Handler
+	reload *LiveReload
+}
+
+func newLiveReloadHandler(root string, reload *LiveReload) http.Handler {
+	return liveReloadHandler{
+		root:   root,
+		files:  http.FileServer(http.Dir(root)),
+		reload: reload,
+	}
+}
+
+func (h liveReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	urlPath := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") {
+		urlPath = path.Join(urlPath, "index.html")
+	}
+
+	// Let the file server handle everything that isn't a page, including its redirects
+	if path.Ext(urlPath) != ".html" || path.Base(urlPath) == "index.html" && !strings.HasSuffix(r.URL.Path, "/") {
+		h.files.ServeHTTP(w, r)
+		return
+	}
+
+	problems := h.reload.currentProblems()
+
+	contents, err := os.ReadFile(filepath.Join(h.root, filepath.FromSlash(urlPath)))
+	if err != nil {
+		if len(problems) == 0 {
+			h.files.ServeHTTP(w, r)
+			return
+		}
+		// The page may be missing because the build failed, show why instead of a 404
+		contents = []byte("<html><body></body></html>")
+	}
+
+	injected := liveReloadScript
+	if len(problems) > 0 {
+		var overlay bytes.Buffer
+		if err := errorOverlayTemplate.Execute(&overlay, problems); err != nil {
+			log.Error().Err(err).Msg("Failed to render error overlay.")
+		}
+		injected = overlay.String() + injected
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(injectBeforeBodyEnd(contents, injected))
+}
+
+func injectBeforeBodyEnd(contents []byte, injected string) []byte {
+	if i := bytes.LastIndex(bytes.ToLower(contents), []byte("</body>")); i >= 0 {
+		return append(append(append([]byte{}, contents[:i]...), injected...), contents[i:]...)
+	}
+	return append(append([]byte{}, contents...), injected...)
+}
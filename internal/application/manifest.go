@@ -0,0 +1,226 @@
+package application
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+)
+
+// manifestVersion must be bumped whenever the manifest layout or the meaning of its fields
+// changes, which forces a full rebuild for existing build directories.
+const manifestVersion = 1
+
+// Manifest records what the previous build consumed and produced so the next build only
+// redoes the work affected by changed files. Output paths are relative to the build path.
+type Manifest struct {
+	Version    int                       `json:"version"`
+	ConfigHash string                    `json:"config_hash"`
+	PagesHash  string                    `json:"pages_hash"`
+	DataHash   string                    `json:"data_hash,omitempty"`
+	ReadFiles  map[string]string         `json:"read_files,omitempty"`
+	Templates  map[string]string         `json:"templates"`
+	Static     map[string]ManifestFile   `json:"static"`
+	Sources    map[string]ManifestSource `json:"sources"`
+	Generated  []string                  `json:"generated"`
+}
+
+// ManifestFile is a static file and the output it was copied to.
+type ManifestFile struct {
+	Hash   string `json:"hash"`
+	Output string `json:"output"`
+}
+
+// ManifestSource is a content file, the page it produced (if any) and the other outputs
+// written during conversion, like media extracted by pandoc.
+type ManifestSource struct {
+	Hash   string   `json:"hash"`
+	Page   string   `json:"page,omitempty"`
+	Link   string   `json:"link,omitempty"`
+	Title  string   `json:"title,omitempty"`
+	Assets []string `json:"assets,omitempty"`
+	Cache  string   `json:"cache,omitempty"`
+}
+
+func NewManifest() Manifest {
+	return Manifest{
+		Version:   manifestVersion,
+		Templates: make(map[string]string),
+		Static:    make(map[string]ManifestFile),
+		Sources:   make(map[string]ManifestSource),
+	}
+}
+
+func manifestPath(config Config) string {
+	return filepath.Join(config.CachePath, "manifest.json")
+}
+
+// LoadManifest reads the manifest of the previous build. A missing or unreadable manifest
+// yields an empty one, which makes the caller fall back to a full build.
+func LoadManifest(config Config) Manifest {
+	data, err := os.ReadFile(manifestPath(config))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Msg("Failed to read build manifest, doing a full build.")
+		}
+		return Manifest{}
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		log.Warn().Err(err).Msg("Failed to parse build manifest, doing a full build.")
+		return Manifest{}
+	}
+
+	return manifest
+}
+
+func SaveManifest(config Config, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(config.CachePath, 0755); err != nil {
+		log.Error().Err(err).Str("cachePath", config.CachePath).Msg("Failed to create cache directory.")
+		return err
+	}
+
+	// Write to a temporary file first so an interrupted build never leaves a truncated manifest
+	tmpPath := manifestPath(config) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		log.Error().Err(err).Msg("Failed to write build manifest.")
+		return err
+	}
+	return os.Rename(tmpPath, manifestPath(config))
+}
+
+// outputs lists every build output the source is responsible for.
+func (s ManifestSource) outputs() []string {
+	var outputs []string
+	if s.Page != "" {
+		outputs = append(outputs, s.Page)
+	}
+	return append(outputs, s.Assets...)
+}
+
+// claimedOutputs lists every build output recorded in the manifest.
+func (m Manifest) claimedOutputs() map[string]bool {
+	claimed := make(map[string]bool)
+	for _, s := range m.Sources {
+		for _, o := range s.outputs() {
+			claimed[o] = true
+		}
+	}
+	for _, s := range m.Static {
+		claimed[s.Output] = true
+	}
+	for _, o := range m.Generated {
+		claimed[o] = true
+	}
+	return claimed
+}
+
+// removeStaleOutputs deletes outputs recorded in prev that aren't produced by next anymore,
+// along with cached conversions that are no longer referenced. It returns the number of
+// outputs removed.
+func removeStaleOutputs(config Config, prev Manifest, next Manifest) int {
+	claimed := next.claimedOutputs()
+	var stale []string
+	for o := range prev.claimedOutputs() {
+		if !claimed[o] {
+			stale = append(stale, o)
+		}
+	}
+	sort.Strings(stale)
+
+	removed := 0
+	for _, o := range stale {
+		outputPath := filepath.Join(config.BuildPath, o)
+		if err := os.Remove(outputPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Str("output", outputPath).Msg("Failed to remove stale output.")
+			continue
+		}
+		log.Debug().Str("output", outputPath).Msg("Removed stale output.")
+		removed++
+		removeEmptyParents(filepath.Dir(outputPath), config.BuildPath)
+	}
+
+	for rel, s := range prev.Sources {
+		if s.Cache == "" {
+			continue
+		}
+		if n, ok := next.Sources[rel]; ok && n.Cache == s.Cache {
+			continue
+		}
+		if err := os.Remove(filepath.Join(config.CachePath, s.Cache)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Str("cache", s.Cache).Msg("Failed to remove stale cache entry.")
+		}
+	}
+
+	return removed
+}
+
+// removeEmptyParents removes dir and its parents while they're empty, stopping at root.
+func removeEmptyParents(dir string, root string) {
+	for dir != root && len(dir) > len(root) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// writeGeneratedOutput writes a file that is derived from the whole site, like a feed, and
+// records it in next. Unchanged files aren't touched so the dev server doesn't see a change.
+func writeGeneratedOutput(config Config, next *Manifest, relPath string, data []byte) error {
+	next.Generated = append(next.Generated, relPath)
+
+	outputPath := filepath.Join(config.BuildPath, relPath)
+	if existing, err := os.ReadFile(outputPath); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		log.Error().Err(err).Str("output", outputPath).Msg("Failed to create output path.")
+		return err
+	}
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		log.Error().Err(err).Str("output", outputPath).Msg("Failed to write generated output.")
+		return err
+	}
+
+	log.Debug().Str("output", outputPath).Msg("Wrote generated output.")
+	return nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
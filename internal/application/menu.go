@@ -0,0 +1,96 @@
+package application
+
+import (
+	"sort"
+	"strings"
+)
+
+// MenuEntry is an item of a navigation menu in config.yaml. Items are sorted by weight, items
+// of the same weight keep their order.
+type MenuEntry struct {
+	Name     string      `yaml:"name"`
+	Url      string      `yaml:"url"`
+	Weight   int         `yaml:"weight,omitempty"`
+	Children []MenuEntry `yaml:"children,omitempty"`
+}
+
+// TMenuItem is a menu item as seen by templates. Current is set on the item linking to the page
+// being rendered, Active also on items whose children are current and on section items like
+// /blog/ for the pages below them.
+type TMenuItem struct {
+	Name     string
+	Url      string
+	Weight   int
+	Current  bool
+	Active   bool
+	Children []TMenuItem
+}
+
+// NewMenus converts the configured menus into sorted template menus without active items.
+func NewMenus(config Config) map[string][]TMenuItem {
+	if len(config.Menus) == 0 {
+		return nil
+	}
+	menus := make(map[string][]TMenuItem, len(config.Menus))
+	for name, entries := range config.Menus {
+		menus[name] = newMenuItems(entries)
+	}
+	return menus
+}
+
+func newMenuItems(entries []MenuEntry) []TMenuItem {
+	if len(entries) == 0 {
+		return nil
+	}
+	items := make([]TMenuItem, len(entries))
+	for i, e := range entries {
+		items[i] = TMenuItem{
+			Name:     e.Name,
+			Url:      e.Url,
+			Weight:   e.Weight,
+			Children: newMenuItems(e.Children),
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Weight < items[j].Weight
+	})
+	return items
+}
+
+// markActiveMenus returns a copy of menus with the items for the page at urlPath marked.
+func markActiveMenus(menus map[string][]TMenuItem, urlPath string) map[string][]TMenuItem {
+	if menus == nil {
+		return nil
+	}
+	marked := make(map[string][]TMenuItem, len(menus))
+	for name, items := range menus {
+		marked[name] = markActiveItems(items, redirectKey(urlPath))
+	}
+	return marked
+}
+
+func markActiveItems(items []TMenuItem, pageKey string) []TMenuItem {
+	if items == nil {
+		return nil
+	}
+	marked := make([]TMenuItem, len(items))
+	for i, item := range items {
+		item.Children = markActiveItems(item.Children, pageKey)
+		item.Current = false
+		item.Active = false
+
+		// External links are never active
+		if !isAbsoluteUrl(item.Url) && item.Url != "" {
+			itemKey := redirectKey(item.Url)
+			item.Current = itemKey == pageKey
+			item.Active = item.Current || (itemKey != "/" && strings.HasSuffix(item.Url, "/") && strings.HasPrefix(pageKey, itemKey+"/"))
+		}
+		for _, child := range item.Children {
+			if child.Active {
+				item.Active = true
+			}
+		}
+		marked[i] = item
+	}
+	return marked
+}
@@ -0,0 +1,193 @@
+package application
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// normalizeMetadata returns a copy of metadata that can be serialized and indexed by name in
+// templates. yaml.v2 decodes nested maps as map[interface{}]interface{}, which are turned into
+// map[string]interface{} at any depth. Other types are kept as decoded.
+func normalizeMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+	normalized := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		normalized[k] = normalizeMetadataValue(v)
+	}
+	return normalized
+}
+
+func normalizeMetadataValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = normalizeMetadataValue(item)
+		}
+		return m
+	case map[string]interface{}:
+		return normalizeMetadata(v)
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = normalizeMetadataValue(item)
+		}
+		return s
+	}
+	return value
+}
+
+// metadataString renders a metadata value as text, nil being empty.
+func metadataString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// metadataNumber reads numbers of any type, including numbers written as strings.
+func metadataNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	case bool, nil:
+		return 0, false
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// metadataTime reads times, including dates written as strings.
+func metadataTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// compareMetadata orders two metadata values. Numbers compare numerically and times
+// chronologically, even when one side is written as a string like in a template. Everything
+// else compares as text. The result is false when only one side is a number or time.
+func compareMetadata(a interface{}, b interface{}) (int, bool) {
+	if x, ok := metadataNumber(a); ok {
+		y, ok := metadataNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	_, aIsTime := a.(time.Time)
+	_, bIsTime := b.(time.Time)
+	if aIsTime || bIsTime {
+		x, okA := metadataTime(a)
+		y, okB := metadataTime(b)
+		if !okA || !okB {
+			return 0, false
+		}
+		return x.Compare(y), true
+	}
+
+	if _, ok := metadataNumber(b); ok {
+		if _, isString := b.(string); !isString {
+			return 0, false
+		}
+	}
+	return strings.Compare(metadataString(a), metadataString(b)), true
+}
+
+// metadataEqual compares loosely, so weight: 3 equals "3" and true equals "true".
+func metadataEqual(a interface{}, b interface{}) bool {
+	if c, ok := compareMetadata(a, b); ok && c == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b) || metadataString(a) == metadataString(b)
+}
+
+// metadataContains reports whether a list contains val, a map has the key val or a string
+// contains val.
+func metadataContains(value interface{}, val interface{}) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if metadataEqual(item, val) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, item := range v {
+			if metadataEqual(item, val) {
+				return true
+			}
+		}
+		return false
+	case map[string]interface{}:
+		_, ok := v[metadataString(val)]
+		return ok
+	case nil:
+		return false
+	}
+	return strings.Contains(metadataString(value), metadataString(val))
+}
+
+// matchMetadata applies a comparison operator of WhereMetadata.
+func matchMetadata(value interface{}, op string, val interface{}) (bool, error) {
+	switch op {
+	case "==", "=", "eq":
+		return metadataEqual(value, val), nil
+	case "!=", "ne":
+		return !metadataEqual(value, val), nil
+	case "<", "lt", "<=", "le", ">", "gt", ">=", "ge":
+		c, ok := compareMetadata(value, val)
+		if !ok {
+			return false, nil
+		}
+		switch op {
+		case "<", "lt":
+			return c < 0, nil
+		case "<=", "le":
+			return c <= 0, nil
+		case ">", "gt":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "contains":
+		return metadataContains(value, val), nil
+	case "in":
+		return metadataContains(val, value), nil
+	}
+	return false, fmt.Errorf("unknown metadata operator %q, expected one of == != < <= > >= contains in", op)
+}
@@ -6,7 +6,17 @@ import (
 	"time"
 )
 
+const (
+	// PageKindPage is a page converted from a document.
+	PageKindPage = "page"
+	// PageKindLink is a bookmark. It has no contents of its own and only a UrlPath when
+	// links.redirect is enabled.
+	PageKindLink = "link"
+)
+
 type TPage struct {
+	Kind            string
+	Link            string
 	SourcePath      string
 	TemplatePath    string
 	DestinationPath string
@@ -15,15 +25,36 @@ type TPage struct {
 	Description     string
 	CreatedAt       time.Time
 	Tags            []string
-	Metadata        map[string]string
+	Metadata        map[string]interface{}
+	SitemapExclude  bool
+	Aliases         []string
+
+	// Paginated is set on pages listing a paginated collection of other pages.
+	Paginated bool
+
+	// Draft and Scheduled mark pages that are only built because of --drafts or --future,
+	// PublishAt is when a scheduled page goes live.
+	Draft     bool
+	Scheduled bool
+	PublishAt time.Time
+
+	// Contents is the converted page body before templating. It's hashed on its own rather
+	// than serialized with the page list, bodies can be large.
+	Contents template.HTML `json:"-"`
 }
 
-type TPageList struct {
-	List []TPage
+// TPageList is a list of pages with filters that return lists again, so they chain in
+// templates, e.g. {{ range (.Pages.FilterByTag "go").SortBy "date" "desc" }}.
+type TPageList []TPage
+
+// List returns the pages as a plain slice. It's kept for templates written against the
+// former {{ .Pages.List }} field.
+func (tpl TPageList) List() []TPage {
+	return tpl
 }
 
-func (tpl *TPageList) FilterByTag(tag string) (ret []TPage) {
-	for _, tp := range tpl.List {
+func (tpl TPageList) FilterByTag(tag string) (ret TPageList) {
+	for _, tp := range tpl {
 		for _, g := range tp.Tags {
 			if g == tag {
 				ret = append(ret, tp)
@@ -34,18 +65,61 @@ func (tpl *TPageList) FilterByTag(tag string) (ret []TPage) {
 	return
 }
 
-func (tpl *TPageList) FilterByUrlPathPrefix(prefix string) (ret []TPage) {
-	for _, tp := range tpl.List {
-		if strings.HasPrefix(tp.UrlPath, prefix) {
+// FilterByUrlPathPrefix keeps pages whose url path starts with prefix. Bookmarks without a URL
+// of their own are left out.
+func (tpl TPageList) FilterByUrlPathPrefix(prefix string) (ret TPageList) {
+	for _, tp := range tpl {
+		if tp.UrlPath != "" && strings.HasPrefix(tp.UrlPath, prefix) {
+			ret = append(ret, tp)
+		}
+	}
+	return
+}
+
+// FilterByMetadata keeps pages whose metadata value for key equals val. Values compare
+// loosely, so a weight of 3 matches "3".
+func (tpl TPageList) FilterByMetadata(key string, val interface{}) (ret TPageList) {
+	for _, tp := range tpl {
+		if v, ok := tp.Metadata[key]; ok && metadataEqual(v, val) {
+			ret = append(ret, tp)
+		}
+	}
+	return
+}
+
+// WhereMetadata keeps pages whose metadata value for key compares to val with op, which is
+// one of == != < <= > >= contains in. Numbers and dates compare by value, e.g.
+// {{ .Pages.WhereMetadata "weight" ">=" 3 }}. Pages without the key are left out.
+func (tpl TPageList) WhereMetadata(key string, op string, val interface{}) (ret TPageList, err error) {
+	for _, tp := range tpl {
+		v, ok := tp.Metadata[key]
+		if !ok {
+			continue
+		}
+		matched, err := matchMetadata(v, op, val)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
 			ret = append(ret, tp)
 		}
 	}
 	return
 }
 
-func (tpl *TPageList) FilterByMetadata(key string, val string) (ret []TPage) {
-	for _, tp := range tpl.List {
-		if v, ok := tp.Metadata[key]; ok && val == v {
+// FilterByMetadataKey keeps pages that have a metadata value for key, whatever it is.
+func (tpl TPageList) FilterByMetadataKey(key string) (ret TPageList) {
+	for _, tp := range tpl {
+		if _, ok := tp.Metadata[key]; ok {
+			ret = append(ret, tp)
+		}
+	}
+	return
+}
+
+func (tpl TPageList) FilterByKind(kind string) (ret TPageList) {
+	for _, tp := range tpl {
+		if tp.Kind == kind {
 			ret = append(ret, tp)
 		}
 	}
@@ -55,6 +129,16 @@ func (tpl *TPageList) FilterByMetadata(key string, val string) (ret []TPage) {
 type TSite struct {
 	Title       string
 	Description string
+	BaseUrl     string
+	Language    string
+	Author      string
+	Params      map[string]interface{}
+
+	// Menus are the configured menus by name, with the items of the rendered page marked.
+	Menus map[string][]TMenuItem
+
+	// Data holds the parsed files of data_path, e.g. .Site.Data.team.members.
+	Data map[string]interface{}
 }
 
 type TData struct {
@@ -62,4 +146,11 @@ type TData struct {
 	Contents template.HTML
 	Pages    TPageList
 	Site     TSite
+
+	// Paginator is set on pages that paginate a collection of pages.
+	Paginator *TPaginator
+
+	// Tags lists every tag of the site, Tag is the tag being listed on a tag page.
+	Tags []TTag
+	Tag  *TTag
 }
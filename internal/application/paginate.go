@@ -0,0 +1,98 @@
+package application
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultPageSize is used when a paginated entry doesn't set page_size.
+const defaultPageSize = 10
+
+// TPaginator is one page of a paginated collection.
+type TPaginator struct {
+	Items      TPageList
+	PageNumber int
+	PageSize   int
+	TotalPages int
+	TotalItems int
+	HasPrev    bool
+	HasNext    bool
+	PrevUrl    string
+	NextUrl    string
+	FirstUrl   string
+	LastUrl    string
+}
+
+// paginationUrl returns the URL of page number n of a collection listed on the page at urlPath.
+// The first page is the page itself, the others live below it, e.g. /blog/page/2/.
+func paginationUrl(urlPath string, n int) string {
+	if n <= 1 {
+		return urlPath
+	}
+	base := strings.TrimSuffix(urlPath, ".html")
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return fmt.Sprintf("%spage/%d/", base, n)
+}
+
+// Paginate splits the pages matched by entry into paginators for page, newest first. There's
+// always at least one paginator, even for an empty collection.
+func Paginate(entry PaginateEntry, page TPage, pages []TPage) []TPaginator {
+	urlPath := page.UrlPath
+	items := TPageList(pages).Exclude(page)
+	if entry.UrlPrefix != "" {
+		items = items.FilterByUrlPathPrefix(entry.UrlPrefix)
+	}
+	if entry.Tag != "" {
+		items = items.FilterByTag(entry.Tag)
+	}
+	if entry.MetadataKey != "" {
+		if entry.MetadataValue != "" {
+			items = items.FilterByMetadata(entry.MetadataKey, entry.MetadataValue)
+		} else {
+			items = items.FilterByMetadataKey(entry.MetadataKey)
+		}
+	}
+	// Sorting by date can't fail
+	items, _ = items.SortBy("date", "desc")
+
+	pageSize := entry.PageSize
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	totalPages := (len(items) + pageSize - 1) / pageSize
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	paginators := make([]TPaginator, 0, totalPages)
+	for n := 1; n <= totalPages; n++ {
+		start := (n - 1) * pageSize
+		end := start + pageSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		p := TPaginator{
+			Items:      items[start:end],
+			PageNumber: n,
+			PageSize:   pageSize,
+			TotalPages: totalPages,
+			TotalItems: len(items),
+			HasPrev:    n > 1,
+			HasNext:    n < totalPages,
+			FirstUrl:   paginationUrl(urlPath, 1),
+			LastUrl:    paginationUrl(urlPath, totalPages),
+		}
+		if p.HasPrev {
+			p.PrevUrl = paginationUrl(urlPath, n-1)
+		}
+		if p.HasNext {
+			p.NextUrl = paginationUrl(urlPath, n+1)
+		}
+		paginators = append(paginators, p)
+	}
+
+	return paginators
+}
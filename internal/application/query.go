@@ -0,0 +1,190 @@
+package application
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TPageGroup is a group of pages returned by TPageList.GroupBy.
+type TPageGroup struct {
+	Key   string
+	Pages TPageList
+}
+
+// Section is the first segment of the page's url path, e.g. "blog" for /blog/2024/post.html.
+// Pages at the root of the site and bookmarks without a URL of their own have no section.
+func (tp TPage) Section() string {
+	trimmed := strings.Trim(tp.UrlPath, "/")
+	if i := strings.Index(trimmed, "/"); i >= 0 {
+		return trimmed[:i]
+	}
+	return ""
+}
+
+// FilterBySection keeps pages in the given section, "" being the root of the site. Bookmarks
+// without a URL of their own are in no section at all.
+func (tpl TPageList) FilterBySection(section string) (ret TPageList) {
+	for _, tp := range tpl {
+		if tp.UrlPath != "" && tp.Section() == section {
+			ret = append(ret, tp)
+		}
+	}
+	return
+}
+
+// Exclude leaves out the given page, usually the current one as in
+// {{ .Pages.Exclude .Page }}.
+func (tpl TPageList) Exclude(page TPage) (ret TPageList) {
+	for _, tp := range tpl {
+		if tp.UrlPath == page.UrlPath && tp.SourcePath == page.SourcePath {
+			continue
+		}
+		ret = append(ret, tp)
+	}
+	return
+}
+
+// SortBy returns the pages sorted by key, which is date, title, url or a metadata key (also
+// written as metadata.<key>). The order is "asc" by default or "desc". Pages missing a
+// metadata key are sorted last, ties keep their previous order.
+func (tpl TPageList) SortBy(key string, order ...string) (TPageList, error) {
+	desc := false
+	if len(order) > 0 {
+		switch strings.ToLower(order[0]) {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			return nil, fmt.Errorf("unknown sort order %q, expected asc or desc", order[0])
+		}
+	}
+
+	var less func(a TPage, b TPage) bool
+	metadataKey := ""
+	switch key {
+	case "date", "created":
+		less = func(a TPage, b TPage) bool {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+	case "title":
+		less = func(a TPage, b TPage) bool {
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		}
+	case "url":
+		less = func(a TPage, b TPage) bool {
+			return a.UrlPath < b.UrlPath
+		}
+	default:
+		metadataKey = strings.TrimPrefix(key, "metadata.")
+		less = func(a TPage, b TPage) bool {
+			c, ok := compareMetadata(a.Metadata[metadataKey], b.Metadata[metadataKey])
+			return ok && c < 0
+		}
+	}
+
+	ret := make(TPageList, len(tpl))
+	copy(ret, tpl)
+	sort.SliceStable(ret, func(i, j int) bool {
+		if metadataKey != "" {
+			_, okI := ret[i].Metadata[metadataKey]
+			_, okJ := ret[j].Metadata[metadataKey]
+			if okI != okJ {
+				return okI
+			}
+		}
+		if desc {
+			return less(ret[j], ret[i])
+		}
+		return less(ret[i], ret[j])
+	})
+	return ret, nil
+}
+
+// Reverse returns the pages in reverse order.
+func (tpl TPageList) Reverse() TPageList {
+	ret := make(TPageList, len(tpl))
+	for i, tp := range tpl {
+		ret[len(tpl)-1-i] = tp
+	}
+	return ret
+}
+
+// Limit returns at most the first n pages.
+func (tpl TPageList) Limit(n int) TPageList {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(tpl) {
+		n = len(tpl)
+	}
+	return tpl[:n]
+}
+
+// Offset skips the first n pages, {{ (.Pages.Offset 10).Limit 10 }} is the second ten.
+func (tpl TPageList) Offset(n int) TPageList {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(tpl) {
+		n = len(tpl)
+	}
+	return tpl[n:]
+}
+
+// First returns the first page or nil when the list is empty.
+func (tpl TPageList) First() *TPage {
+	if len(tpl) == 0 {
+		return nil
+	}
+	return &tpl[0]
+}
+
+// Last returns the last page or nil when the list is empty.
+func (tpl TPageList) Last() *TPage {
+	if len(tpl) == 0 {
+		return nil
+	}
+	return &tpl[len(tpl)-1]
+}
+
+// GroupBy groups the pages by year, month (as 2006-01), tag, section or a metadata key. Groups
+// are in the order their first page appears, so sort before grouping, e.g.
+// {{ range ((.Pages.SortBy "date" "desc").GroupBy "year") }}. A page is in a group for each of
+// its tags, pages without a tag or metadata key are left out, as are bookmarks without a URL
+// when grouping by section.
+func (tpl TPageList) GroupBy(key string) []TPageGroup {
+	var groups []TPageGroup
+	index := make(map[string]int)
+	add := func(groupKey string, tp TPage) {
+		i, ok := index[groupKey]
+		if !ok {
+			i = len(groups)
+			index[groupKey] = i
+			groups = append(groups, TPageGroup{Key: groupKey})
+		}
+		groups[i].Pages = append(groups[i].Pages, tp)
+	}
+
+	for _, tp := range tpl {
+		switch key {
+		case "year":
+			add(tp.CreatedAt.Format("2006"), tp)
+		case "month":
+			add(tp.CreatedAt.Format("2006-01"), tp)
+		case "tag":
+			for _, tag := range tp.Tags {
+				add(tag, tp)
+			}
+		case "section":
+			if tp.UrlPath != "" {
+				add(tp.Section(), tp)
+			}
+		default:
+			if v, ok := tp.Metadata[strings.TrimPrefix(key, "metadata.")]; ok {
+				add(metadataString(v), tp)
+			}
+		}
+	}
+	return groups
+}
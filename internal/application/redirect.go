@@ -0,0 +1,234 @@
+package application
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// redirectStubTemplate is written at the old URL of a redirect so it also works on hosts that
+// only serve files.
+var redirectStubTemplate = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Redirecting to {{ .To }}</title>
+<link rel="canonical" href="{{ .Canonical }}">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url={{ .To }}">
+</head>
+<body>
+<p>This page has moved to <a href="{{ .To }}">{{ .Canonical }}</a>.</p>
+</body>
+</html>
+`))
+
+// Redirect sends visitors from one URL path to another URL path or an absolute URL.
+type Redirect struct {
+	From   string
+	To     string
+	Status int
+}
+
+// redirectKey normalizes a URL path so /old, /old/, /old.html and /old/index.html all match
+// the same redirect.
+func redirectKey(urlPath string) string {
+	key := path.Clean("/" + urlPath)
+	key = strings.TrimSuffix(key, "/index.html")
+	key = strings.TrimSuffix(key, ".html")
+	if key == "" {
+		return "/"
+	}
+	return key
+}
+
+// redirectStubPath returns where the stub for a redirect from urlPath is written, relative to
+// the build directory.
+func redirectStubPath(urlPath string) string {
+	cleaned := path.Clean("/" + urlPath)
+	if path.Ext(cleaned) == ".html" {
+		return filepath.FromSlash(strings.TrimPrefix(cleaned, "/"))
+	}
+	return filepath.Join(filepath.FromSlash(strings.TrimPrefix(cleaned, "/")), "index.html")
+}
+
+// CollectRedirects gathers the configured redirects, the aliases of every page and, when
+// links.redirect is enabled, the bookmarks, sorted by the path they redirect from. Two
+// redirects from the same path are an error, so is a redirect from the URL of a page.
+func CollectRedirects(config Config, pages []TPage) ([]Redirect, error) {
+	var redirects []Redirect
+	for _, r := range config.Redirects {
+		if r.From == "" || r.To == "" {
+			return nil, fmt.Errorf("redirect from %q to %q needs both from and to", r.From, r.To)
+		}
+		status := r.Status
+		if status == 0 {
+			status = http.StatusMovedPermanently
+		}
+		if status < 300 || status > 399 {
+			return nil, fmt.Errorf("redirect from %q has status %d, expected a 3xx status", r.From, status)
+		}
+		redirects = append(redirects, Redirect{From: "/" + strings.TrimPrefix(r.From, "/"), To: r.To, Status: status})
+	}
+	for _, p := range pages {
+		if p.Kind == PageKindLink && p.UrlPath != "" {
+			redirects = append(redirects, Redirect{From: p.UrlPath, To: p.Link, Status: http.StatusFound})
+		}
+		if len(p.Aliases) > 0 && p.UrlPath == "" {
+			return nil, fmt.Errorf("%s has aliases but no URL to redirect them to, bookmarks only get one with links.redirect", p.SourcePath)
+		}
+		for _, alias := range p.Aliases {
+			redirects = append(redirects, Redirect{From: "/" + strings.TrimPrefix(alias, "/"), To: p.UrlPath, Status: http.StatusMovedPermanently})
+		}
+	}
+
+	sort.SliceStable(redirects, func(i, j int) bool {
+		return redirects[i].From < redirects[j].From
+	})
+
+	// Keys ignore .html and /index.html, so /foo.html would otherwise redirect /foo/ to itself
+	pageKeys := make(map[string]string)
+	for _, p := range pages {
+		if p.Kind != PageKindLink && p.UrlPath != "" {
+			pageKeys[redirectKey(p.UrlPath)] = p.SourcePath
+		}
+	}
+
+	seen := make(map[string]Redirect)
+	for _, r := range redirects {
+		key := redirectKey(r.From)
+		if source, ok := pageKeys[key]; ok {
+			return nil, fmt.Errorf("redirect from %s to %s conflicts with the page built from %s", r.From, r.To, source)
+		}
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("redirect from %s to %s conflicts with the redirect to %s", r.From, r.To, other.To)
+		}
+		seen[key] = r
+	}
+
+	return redirects, nil
+}
+
+// GenerateRedirects writes a meta refresh stub for every redirect, plus the server config
+// files selected under redirect_files. Stubs never replace another output of the build, so
+// it has to run after everything else was written.
+func GenerateRedirects(config Config, pages []TPage, next *Manifest) ([]Redirect, error) {
+	redirects, err := CollectRedirects(config, pages)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to collect redirects.")
+		return nil, err
+	}
+
+	// Everything written so far, pages as well as tag pages, feeds and static files
+	claimed := next.claimedOutputs()
+
+	for _, r := range redirects {
+		relPath := redirectStubPath(r.From)
+		if claimed[relPath] {
+			err := fmt.Errorf("redirect from %s would overwrite %s", r.From, filepath.ToSlash(relPath))
+			log.Error().Err(err).Msg("Failed to generate redirect.")
+			return nil, err
+		}
+
+		// The canonical link has to be absolute, the refresh stays relative so it works locally
+		canonical := r.To
+		if strings.HasPrefix(canonical, "/") {
+			canonical = config.BaseUrl + canonical
+		}
+		var stub bytes.Buffer
+		if err := redirectStubTemplate.Execute(&stub, struct{ To, Canonical string }{r.To, canonical}); err != nil {
+			log.Error().Err(err).Str("from", r.From).Msg("Failed to render redirect.")
+			return nil, err
+		}
+		if err := writeGeneratedOutput(config, next, relPath, stub.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.RedirectFiles.Netlify {
+		var b strings.Builder
+		for _, r := range redirects {
+			fmt.Fprintf(&b, "%s %s %d\n", r.From, r.To, r.Status)
+		}
+		if err := writeGeneratedOutput(config, next, "_redirects", []byte(b.String())); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.RedirectFiles.Nginx {
+		// A map only yields the target, so every status gets a map of its own
+		byStatus := make(map[int][]Redirect)
+		var statuses []int
+		for _, r := range redirects {
+			if _, ok := byStatus[r.Status]; !ok {
+				statuses = append(statuses, r.Status)
+			}
+			byStatus[r.Status] = append(byStatus[r.Status], r)
+		}
+		sort.Ints(statuses)
+		if len(statuses) == 0 {
+			statuses = []int{http.StatusMovedPermanently}
+		}
+
+		for _, status := range statuses {
+			name, variable := nginxRedirectMap(status)
+			var b strings.Builder
+			fmt.Fprintf(&b, "# Use with: map $uri %s { include %s; }\n", variable, name)
+			fmt.Fprintf(&b, "# and: if (%s) { return %d %s; }\n", variable, status, variable)
+			for _, r := range byStatus[status] {
+				fmt.Fprintf(&b, "%s %s;\n", r.From, r.To)
+			}
+			if err := writeGeneratedOutput(config, next, name, []byte(b.String())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return redirects, nil
+}
+
+// nginxRedirectMap returns the file name and variable of the nginx map for a status. 301s
+// keep the plain redirects.map name.
+func nginxRedirectMap(status int) (string, string) {
+	if status == http.StatusMovedPermanently {
+		return "redirects.map", "$redirect_uri"
+	}
+	return fmt.Sprintf("redirects-%d.map", status), fmt.Sprintf("$redirect_%d_uri", status)
+}
+
+// redirectHandler answers requests for old URLs with the redirects of the last successful
+// build, the same way the generated server config files would. Pages in the build directory
+// are served as is, only redirect stubs are replaced by real redirects.
+type redirectHandler struct {
+	root   string
+	reload *LiveReload
+	next   http.Handler
+}
+
+func (h redirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if redirect, ok := h.reload.lookupRedirect(r.URL.Path); ok && !h.servesPage(r.URL.Path, redirect) {
+		log.Trace().Str("url", r.URL.Path).Str("to", redirect.To).Msg("Redirecting.")
+		http.Redirect(w, r, redirect.To, redirect.Status)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// servesPage reports whether urlPath resolves to a file other than the stub of redirect.
+func (h redirectHandler) servesPage(urlPath string, redirect Redirect) bool {
+	resolved, ok := resolvePageUrl(h.root, urlPath)
+	if !ok {
+		return false
+	}
+	if strings.HasSuffix(resolved, "/") {
+		resolved = path.Join(resolved, "index.html")
+	}
+	return filepath.FromSlash(strings.TrimPrefix(path.Clean(resolved), "/")) != redirectStubPath(redirect.From)
+}
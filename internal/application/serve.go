@@ -1,21 +1,37 @@
 package application
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
-func ServeOutputDirectory(outputDir string, addr string, wg *sync.WaitGroup) error {
+func ServeOutputDirectory(outputDir string, addr string, wg *sync.WaitGroup, reload *LiveReload) error {
 	// Set up file server to serve the output directory
-	fs := http.FileServer(http.Dir(outputDir))
-	http.Handle("/", fs)
+	mux := http.NewServeMux()
+	if reload != nil {
+		// Pages get a script that listens for rebuilds and an overlay when a rebuild failed,
+		// old URLs are redirected like the generated server config files would
+		mux.Handle(liveReloadPath, reload)
+		mux.Handle("/", redirectHandler{
+			root:   outputDir,
+			reload: reload,
+			next:   prettyUrlHandler{root: outputDir, next: newLiveReloadHandler(outputDir, reload)},
+		})
+	} else {
+		mux.Handle("/", prettyUrlHandler{root: outputDir, next: http.FileServer(http.Dir(outputDir))})
+	}
 
-	server := &http.Server{Addr: addr}
+	server := &http.Server{Addr: addr, Handler: mux}
 
 	log.Info().Str("addr", addr).Msg("Serving files.")
 
@@ -28,8 +44,15 @@ func ServeOutputDirectory(outputDir string, addr string, wg *sync.WaitGroup) err
 		<-sigChan // Wait for the signal
 		log.Info().Msg("Shutting down HTTP server.")
 
+		// Disconnect live reload streams, they'd keep the server from shutting down
+		if reload != nil {
+			reload.Close()
+		}
+
 		// Shutdown the server gracefully with a timeout of 5 seconds
-		err := server.Shutdown(nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := server.Shutdown(ctx)
 		if err != nil {
 			log.Error().Err(err).Msg("Error during server shutdown.")
 		}
@@ -51,3 +74,50 @@ func ServeOutputDirectory(outputDir string, addr string, wg *sync.WaitGroup) err
 
 	return nil
 }
+
+// prettyUrlHandler lets /foo/bar.html, /foo/bar/ and /foo/bar resolve to the same page no
+// matter whether it was written as foo/bar.html or foo/bar/index.html.
+type prettyUrlHandler struct {
+	root string
+	next http.Handler
+}
+
+func (h prettyUrlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if resolved, ok := resolvePageUrl(h.root, r.URL.Path); ok && resolved != r.URL.Path {
+		log.Trace().Str("url", r.URL.Path).Str("resolved", resolved).Msg("Resolved page URL.")
+		r.URL.Path = resolved
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// resolvePageUrl finds the URL a page is actually served at when urlPath doesn't exist as is.
+func resolvePageUrl(root string, urlPath string) (string, bool) {
+	cleaned := path.Clean("/" + urlPath)
+	exists := func(p string) bool {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(p)))
+		return err == nil && !info.IsDir()
+	}
+
+	switch {
+	case strings.HasSuffix(urlPath, "/"):
+		if exists(path.Join(cleaned, "index.html")) {
+			return urlPath, true
+		}
+		if cleaned != "/" && exists(cleaned+".html") {
+			return cleaned + ".html", true
+		}
+	case strings.HasSuffix(cleaned, ".html") && path.Base(cleaned) != "index.html":
+		if exists(cleaned) {
+			return urlPath, true
+		}
+		if pretty := strings.TrimSuffix(cleaned, ".html"); exists(path.Join(pretty, "index.html")) {
+			return pretty + "/", true
+		}
+	case path.Ext(cleaned) == "":
+		if exists(cleaned + ".html") {
+			return cleaned + ".html", true
+		}
+	}
+
+	return urlPath, false
+}
@@ -0,0 +1,153 @@
+package application
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// sitemapMaxUrls is the most URLs a single sitemap may list according to sitemaps.org.
+const sitemapMaxUrls = 50000
+
+const sitemapNs = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapUrlSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Urls    []sitemapUrl `xml:"url"`
+}
+
+type sitemapUrl struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name         `xml:"sitemapindex"`
+	Xmlns    string           `xml:"xmlns,attr"`
+	Sitemaps []sitemapPointer `xml:"sitemap"`
+}
+
+type sitemapPointer struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// GenerateSitemap writes sitemap.xml listing every page that isn't excluded. Past 50,000 pages
+// the URLs are split over sitemap-1.xml, sitemap-2.xml, ... and sitemap.xml becomes their index.
+func GenerateSitemap(config Config, pages []TPage, next *Manifest) error {
+	if !config.Sitemap.Enabled {
+		return nil
+	}
+	if config.BaseUrl == "" {
+		err := errors.New("base_url is required to generate a sitemap")
+		log.Error().Err(err).Msg("Failed to generate sitemap.")
+		return err
+	}
+
+	urls := make([]sitemapUrl, 0, len(pages))
+	for _, p := range pages {
+		if p.SitemapExclude {
+			continue
+		}
+
+		u := sitemapUrl{
+			Loc:        config.BaseUrl + p.UrlPath,
+			ChangeFreq: metadataString(p.Metadata["changefreq"]),
+			Priority:   metadataString(p.Metadata["priority"]),
+		}
+		if !p.CreatedAt.IsZero() {
+			u.LastMod = p.CreatedAt.Format(time.RFC3339)
+		}
+		urls = append(urls, u)
+	}
+
+	if len(urls) <= sitemapMaxUrls {
+		data, err := marshalXml(sitemapUrlSet{Xmlns: sitemapNs, Urls: urls})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to render sitemap.")
+			return err
+		}
+		return writeGeneratedOutput(config, next, "sitemap.xml", data)
+	}
+
+	index := sitemapIndex{Xmlns: sitemapNs}
+	for i := 0; i*sitemapMaxUrls < len(urls); i++ {
+		end := (i + 1) * sitemapMaxUrls
+		if end > len(urls) {
+			end = len(urls)
+		}
+		chunk := urls[i*sitemapMaxUrls : end]
+
+		name := fmt.Sprintf("sitemap-%d.xml", i+1)
+		data, err := marshalXml(sitemapUrlSet{Xmlns: sitemapNs, Urls: chunk})
+		if err != nil {
+			log.Error().Err(err).Str("sitemap", name).Msg("Failed to render sitemap.")
+			return err
+		}
+		if err := writeGeneratedOutput(config, next, name, data); err != nil {
+			return err
+		}
+
+		pointer := sitemapPointer{Loc: config.BaseUrl + "/" + name}
+		for _, u := range chunk {
+			if u.LastMod > pointer.LastMod {
+				pointer.LastMod = u.LastMod
+			}
+		}
+		index.Sitemaps = append(index.Sitemaps, pointer)
+	}
+
+	data, err := marshalXml(index)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to render sitemap index.")
+		return err
+	}
+	return writeGeneratedOutput(config, next, "sitemap.xml", data)
+}
+
+// GenerateRobots writes robots.txt from the configured rules, pointing crawlers at the sitemap.
+func GenerateRobots(config Config, next *Manifest) error {
+	if !config.Robots.Enabled {
+		return nil
+	}
+
+	rules := config.Robots.Rules
+	if len(rules) == 0 {
+		// Allow everything by default
+		rules = []RobotsRule{{UserAgent: "*"}}
+	}
+
+	var b strings.Builder
+	for i, rule := range rules {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		userAgent := rule.UserAgent
+		if userAgent == "" {
+			userAgent = "*"
+		}
+		fmt.Fprintf(&b, "User-agent: %s\n", userAgent)
+		for _, allow := range rule.Allow {
+			fmt.Fprintf(&b, "Allow: %s\n", allow)
+		}
+		for _, disallow := range rule.Disallow {
+			fmt.Fprintf(&b, "Disallow: %s\n", disallow)
+		}
+		if len(rule.Allow) == 0 && len(rule.Disallow) == 0 {
+			b.WriteString("Disallow:\n")
+		}
+	}
+
+	if config.Sitemap.Enabled && config.BaseUrl != "" {
+		fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", config.BaseUrl)
+	}
+
+	return writeGeneratedOutput(config, next, "robots.txt", []byte(b.String()))
+}
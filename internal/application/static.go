@@ -4,6 +4,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+
+	"github.com/rs/zerolog/log"
 )
 
 func CopyDir(sourceDir, destinationDir string) error {
@@ -70,3 +72,73 @@ func CopyFile(sourcePath, destinationPath string) error {
 
 	return nil
 }
+
+// staticOutputs lists the outputs of the static files, relative to the build path.
+func staticOutputs(config Config) ([]string, error) {
+	var outputs []string
+	if !fileExists(config.StaticPath) {
+		return outputs, nil
+	}
+
+	err := filepath.Walk(config.StaticPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(config.StaticPath, path)
+		if err != nil {
+			return err
+		}
+		outputs = append(outputs, relPath)
+		return nil
+	})
+	return outputs, err
+}
+
+// SyncStaticFiles copies static files that are new or changed since the previous build into
+// the build directory, records them in next and returns the relative paths it copied.
+func SyncStaticFiles(config Config, prev Manifest, next *Manifest) ([]string, error) {
+	var copied []string
+
+	if !fileExists(config.StaticPath) {
+		return copied, nil
+	}
+
+	err := filepath.Walk(config.StaticPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(config.StaticPath, path)
+		if err != nil {
+			return err
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			log.Error().Err(err).Str("file", path).Msg("Failed to hash static file.")
+			return err
+		}
+
+		destinationPath := filepath.Join(config.BuildPath, relPath)
+		next.Static[relPath] = ManifestFile{Hash: hash, Output: relPath}
+		if p, ok := prev.Static[relPath]; ok && p.Hash == hash && fileExists(destinationPath) {
+			return nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(destinationPath), 0755); err != nil {
+			return err
+		}
+		if err := CopyFile(path, destinationPath); err != nil {
+			log.Error().Err(err).Str("file", path).Msg("Failed to copy static file.")
+			return err
+		}
+		copied = append(copied, relPath)
+
+		return nil
+	})
+
+	return copied, err
+}
@@ -0,0 +1,132 @@
+package application
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultTagsOutputPath is where tag pages are written when taxonomy.output_path isn't set.
+const defaultTagsOutputPath = "tags"
+
+// TTag is a tag along with the pages tagged with it, newest first.
+type TTag struct {
+	Name    string
+	Slug    string
+	UrlPath string
+	Pages   TPageList
+}
+
+// tagsUrlPath returns the URL of the tag index, e.g. /tags/.
+func tagsUrlPath(config Config) string {
+	outputPath := config.Taxonomy.OutputPath
+	if outputPath == "" {
+		outputPath = defaultTagsOutputPath
+	}
+	return path.Join("/", filepath.ToSlash(outputPath)) + "/"
+}
+
+// CollectTags groups pages by tag. Tags are sorted by name, and tags that only differ in
+// spelling but share a slug, like "Go" and "go", are merged under the first name seen.
+func CollectTags(config Config, pages []TPage) []TTag {
+	bySlug := make(map[string]*TTag)
+	var slugs []string
+	for _, p := range pages {
+		seen := make(map[string]bool)
+		for _, name := range p.Tags {
+			slug := Slugify(name)
+			if slug == "" || seen[slug] {
+				continue
+			}
+			seen[slug] = true
+
+			tag, ok := bySlug[slug]
+			if !ok {
+				tag = &TTag{
+					Name:    name,
+					Slug:    slug,
+					UrlPath: tagsUrlPath(config) + slug + "/",
+				}
+				bySlug[slug] = tag
+				slugs = append(slugs, slug)
+			} else if tag.Name != name {
+				log.Debug().Str("tag", name).Str("mergedInto", tag.Name).Msg("Merging tags with the same slug.")
+			}
+			tag.Pages = append(tag.Pages, p)
+		}
+	}
+
+	tags := make([]TTag, 0, len(slugs))
+	for _, slug := range slugs {
+		tag := bySlug[slug]
+		sort.SliceStable(tag.Pages, func(i, j int) bool {
+			return tag.Pages[i].CreatedAt.After(tag.Pages[j].CreatedAt)
+		})
+		tags = append(tags, *tag)
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name)
+	})
+
+	return tags
+}
+
+// GenerateTagPages renders the tag index at /tags/ and a listing page per tag at /tags/<slug>/
+// with the templates configured under taxonomy.
+func GenerateTagPages(config Config, templates *TemplateSet, base TData, next *Manifest) error {
+	taxonomy := config.Taxonomy
+	if taxonomy.IndexTemplate == "" && taxonomy.TagTemplate == "" {
+		return nil
+	}
+
+	indexUrlPath := tagsUrlPath(config)
+
+	if taxonomy.IndexTemplate != "" {
+		tData := base
+		tData.Page = TPage{
+			TemplatePath: taxonomy.IndexTemplate,
+			UrlPath:      indexUrlPath,
+			Title:        "Tags",
+		}
+		if err := renderGeneratedPage(config, templates, tData, next); err != nil {
+			return err
+		}
+	}
+
+	if taxonomy.TagTemplate != "" {
+		for i := range base.Tags {
+			tag := base.Tags[i]
+			tData := base
+			tData.Tag = &tag
+			tData.Page = TPage{
+				TemplatePath: taxonomy.TagTemplate,
+				UrlPath:      tag.UrlPath,
+				Title:        tag.Name,
+				Tags:         []string{tag.Name},
+			}
+			if err := renderGeneratedPage(config, templates, tData, next); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// renderGeneratedPage renders a page that has no source file, writing it to the index.html
+// of the page's URL path.
+func renderGeneratedPage(config Config, templates *TemplateSet, tData TData, next *Manifest) error {
+	relPath := filepath.Join(filepath.FromSlash(strings.TrimPrefix(tData.Page.UrlPath, "/")), "index.html")
+	tData.Page.DestinationPath = filepath.Join(config.BuildPath, relPath)
+
+	output, err := RenderTemplate(templates, tData)
+	if err != nil {
+		log.Error().Err(err).Str("url", tData.Page.UrlPath).Msg("Failed to render generated page.")
+		return err
+	}
+
+	return writeGeneratedOutput(config, next, relPath, output)
+}
@@ -1,21 +1,35 @@
 package application
 
 import (
+	"bytes"
+	"fmt"
 	"html/template"
 	"os"
-	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"text/template/parse"
 
 	"github.com/rs/zerolog/log"
 )
 
-func hasPrefix(prefix string, s string) bool {
-	log.Logger.Trace().Str("prefix", prefix).Str("string", s).Msg("Called HasPrefix.")
-	return strings.HasPrefix(s, prefix)
+// TemplateSet holds every template of the site, parsed once per build and shared by all pages.
+type TemplateSet struct {
+	root    string
+	base    *template.Template
+	sources map[string]string
+	reads   *fileReads
+
+	mu    sync.Mutex
+	pages map[string]*template.Template
 }
 
-func loadTemplates(baseTemplateDirPath string, templateName string) *template.Template {
+// LoadTemplateSet parses every file in the templates directory. Templates are named by their
+// path relative to the templates directory, like `{{ template "partials/header.html" . }}`,
+// and also by their file name as long as no other template has the same one.
+func LoadTemplateSet(config Config) (*TemplateSet, error) {
+	baseTemplateDirPath := config.TemplatesPath
 	var paths []string
 	err := filepath.Walk(baseTemplateDirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -27,45 +41,215 @@ func loadTemplates(baseTemplateDirPath string, templateName string) *template.Te
 		return nil
 	})
 	if err != nil {
-		log.Fatal().Err(err).Str("baseTemplateDirPath", baseTemplateDirPath).Msg("Failed to collect templates from base dir.")
+		log.Error().Err(err).Str("baseTemplateDirPath", baseTemplateDirPath).Msg("Failed to collect templates from base dir.")
+		return nil, err
 	}
 
 	log.Trace().Any("paths", paths).Msg("Template paths.")
 
 	// Parse all the templates (incl possible deps) with the function map configured
-	tmpl, err := template.New("__sentinel").Funcs(template.FuncMap{
-		"HasPrefix": hasPrefix,
-	}).ParseFiles(paths...)
+	reads := &fileReads{}
+	ts := &TemplateSet{
+		root:    baseTemplateDirPath,
+		base:    template.New("__sentinel").Funcs(templateFuncs(config, reads)),
+		sources: make(map[string]string),
+		reads:   reads,
+		pages:   make(map[string]*template.Template),
+	}
+	for _, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			log.Error().Err(err).Str("template", path).Msg("Failed to read template.")
+			return nil, &FileError{Path: path, Err: err}
+		}
+		name := ts.name(path)
+		if _, err := ts.base.New(name).Parse(string(contents)); err != nil {
+			log.Error().Err(err).Str("template", path).Msg("Failed to parse template.")
+			return nil, &FileError{Path: path, Err: err}
+		}
+		ts.sources[name] = string(contents)
+	}
+
+	// Nested templates can also be included by their file name, unless it's ambiguous
+	byBase := make(map[string][]string)
+	for name := range ts.sources {
+		byBase[filepath.Base(name)] = append(byBase[filepath.Base(name)], name)
+	}
+	ambiguous := make(map[string][]string)
+	for base, names := range byBase {
+		if _, ok := ts.sources[base]; ok {
+			continue
+		}
+		if len(names) > 1 {
+			sort.Strings(names)
+			ambiguous[base] = names
+			continue
+		}
+		if _, err := ts.base.New(base).Parse(ts.sources[names[0]]); err != nil {
+			return nil, &FileError{Path: filepath.Join(baseTemplateDirPath, names[0]), Err: err}
+		}
+	}
+	if err := checkAmbiguousTemplates(ts, ambiguous); err != nil {
+		log.Error().Err(err).Msg("Failed to resolve template names.")
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// checkAmbiguousTemplates fails when a template includes another by a file name that several
+// templates share, e.g. "single.html" with both blog/single.html and docs/single.html around.
+func checkAmbiguousTemplates(ts *TemplateSet, ambiguous map[string][]string) error {
+	if len(ambiguous) == 0 {
+		return nil
+	}
+
+	// Blocks defined inside a file are templates of their own, so all of them are checked
+	templates := ts.base.Templates()
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name() < templates[j].Name()
+	})
+	for _, tmpl := range templates {
+		if tmpl.Tree == nil {
+			continue
+		}
+		var err error
+		walkTemplateNodes(tmpl.Tree.Root, func(n *parse.TemplateNode) {
+			if candidates, ok := ambiguous[n.Name]; ok && err == nil {
+				err = fmt.Errorf("template %q is ambiguous, include one of %s by its path", n.Name, strings.Join(candidates, ", "))
+			}
+		})
+		if err == nil {
+			continue
+		}
+		if _, ok := ts.sources[tmpl.Name()]; ok {
+			return &FileError{Path: filepath.Join(ts.root, filepath.FromSlash(tmpl.Name())), Err: err}
+		}
+		return fmt.Errorf("template %q: %w", tmpl.Name(), err)
+	}
+	return nil
+}
+
+// walkTemplateNodes calls fn for every {{ template }} action below node.
+func walkTemplateNodes(node parse.Node, fn func(*parse.TemplateNode)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			walkTemplateNodes(child, fn)
+		}
+	case *parse.IfNode:
+		walkTemplateNodes(n.List, fn)
+		walkTemplateNodes(n.ElseList, fn)
+	case *parse.RangeNode:
+		walkTemplateNodes(n.List, fn)
+		walkTemplateNodes(n.ElseList, fn)
+	case *parse.WithNode:
+		walkTemplateNodes(n.List, fn)
+		walkTemplateNodes(n.ElseList, fn)
+	case *parse.TemplateNode:
+		fn(n)
+	}
+}
+
+// name returns the name of the template at the given path, which is the same on every OS.
+func (ts *TemplateSet) name(templatePath string) string {
+	rel, err := filepath.Rel(ts.root, templatePath)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to parse templates.")
+		return templatePath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// lookup returns the set with the given template parsed last, so that the blocks it defines
+// take precedence over blocks of the same name defined by other templates.
+func (ts *TemplateSet) lookup(templateName string) (*template.Template, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if tmpl, ok := ts.pages[templateName]; ok {
+		return tmpl, nil
 	}
 
-	// Then re-load the template we care about and make sure it's named appropriately so it can be referenced
-	return template.Must(tmpl.New(templateName).ParseFiles(filepath.Join(baseTemplateDirPath, templateName)))
+	contents, ok := ts.sources[templateName]
+	if !ok {
+		return nil, fmt.Errorf("template %q not found", templateName)
+	}
+
+	tmpl, err := ts.base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmpl.New(templateName).Parse(contents); err != nil {
+		return nil, err
+	}
+
+	ts.pages[templateName] = tmpl
+	return tmpl, nil
 }
 
-func ApplyTemplateToFile(tData TData) error {
-	templatePath := tData.Page.TemplatePath
-	contentHtmlPath := tData.Page.DestinationPath
+// hashTemplates returns the content hash of every file in the templates directory.
+func hashTemplates(baseTemplateDirPath string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	err := filepath.Walk(baseTemplateDirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		hashes[path] = hash
+		return nil
+	})
+	return hashes, err
+}
 
-	tmpl := loadTemplates(path.Dir(templatePath), path.Base(templatePath))
+// RenderTemplate renders the page's template with the given data.
+func RenderTemplate(templates *TemplateSet, tData TData) ([]byte, error) {
+	templatePath := tData.Page.TemplatePath
+	templateName := templates.name(templatePath)
 
-	// Create a buffer to hold the rendered output
-	output, err := os.Create(contentHtmlPath)
+	tmpl, err := templates.lookup(templateName)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to create output file.")
+		log.Error().Err(err).Str("templatePath", templatePath).Msg("Failed to load template.")
+		return nil, &FileError{Path: templatePath, Err: err}
 	}
-	defer output.Close()
 
-	log.Trace().Str("templatePath", path.Base(templatePath)).Any("data.Page", tData.Page).Msg("Attempting to apply template with the following data.")
+	// Menus are shared by all pages, only the active items differ
+	tData.Site.Menus = markActiveMenus(tData.Site.Menus, tData.Page.UrlPath)
 
-	// Apply the template to the contents and write the output to the file
-	if err := tmpl.ExecuteTemplate(output, path.Base(templatePath), tData); err != nil {
+	log.Trace().Str("templateName", templateName).Any("data.Page", tData.Page).Msg("Attempting to apply template with the following data.")
+
+	var output bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&output, templateName, tData); err != nil {
 		log.Error().Err(err).Msg("Failed to apply template.")
+		return nil, &FileError{Path: templatePath, Err: err}
+	}
+
+	return output.Bytes(), nil
+}
+
+func ApplyTemplateToFile(templates *TemplateSet, tData TData) error {
+	contentHtmlPath := tData.Page.DestinationPath
+
+	output, err := RenderTemplate(templates, tData)
+	if err != nil {
+		return err
+	}
+
+	// Apply the template to the contents and write the output to the file
+	if err := os.WriteFile(contentHtmlPath, output, 0644); err != nil {
+		log.Error().Err(err).Msg("Failed to create output file.")
 		return err
 	}
 
-	log.Trace().Str("template", path.Base(templatePath)).Str("file", contentHtmlPath).Msg("Successfully applied template to file.")
+	log.Trace().Str("template", templates.name(tData.Page.TemplatePath)).Str("file", contentHtmlPath).Msg("Successfully applied template to file.")
 
 	return nil
 }
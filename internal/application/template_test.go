@@ -0,0 +1,100 @@
+package application
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplateSetNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		page    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "nested partial by file name",
+			files: map[string]string{
+				"page.html":            `{{ template "header.html" . }}body`,
+				"partials/header.html": `header `,
+			},
+			page: "page.html",
+			want: "header body",
+		},
+		{
+			name: "nested partial by path",
+			files: map[string]string{
+				"page.html":            `{{ template "partials/header.html" . }}body`,
+				"partials/header.html": `header `,
+			},
+			page: "page.html",
+			want: "header body",
+		},
+		{
+			name: "same file name in different directories",
+			files: map[string]string{
+				"blog/single.html": `blog`,
+				"docs/single.html": `docs`,
+			},
+			page: "docs/single.html",
+			want: "docs",
+		},
+		{
+			name: "top-level template wins over nested ones",
+			files: map[string]string{
+				"page.html":            `{{ template "header.html" . }}`,
+				"header.html":          `top`,
+				"partials/header.html": `nested`,
+			},
+			page: "page.html",
+			want: "top",
+		},
+		{
+			name: "ambiguous file name",
+			files: map[string]string{
+				"page.html":        `{{ if true }}{{ template "header.html" . }}{{ end }}`,
+				"blog/header.html": `blog`,
+				"docs/header.html": `docs`,
+			},
+			wantErr: `template "header.html" is ambiguous, include one of blog/header.html, docs/header.html by its path`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, contents := range tt.files {
+				path := filepath.Join(root, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			templates, err := LoadTemplateSet(Config{TemplatesPath: root})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadTemplateSet() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadTemplateSet() error = %v", err)
+			}
+
+			tData := TData{Page: TPage{TemplatePath: filepath.Join(root, filepath.FromSlash(tt.page))}}
+			got, err := RenderTemplate(templates, tData)
+			if err != nil {
+				t.Fatalf("RenderTemplate() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("RenderTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
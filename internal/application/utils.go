@@ -1,8 +1,11 @@
 package application
 
 import (
+	"bytes"
 	"os"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/rs/zerolog/log"
 	"golang.org/x/net/html"
@@ -36,8 +39,23 @@ func GetTitleForHtmlFile(filePath string) (val *string) {
 		return
 	}
 
+	return findTitle(doc)
+}
+
+// GetTitleForHtml returns the text of the first h1 in an HTML fragment.
+func GetTitleForHtml(contents []byte) (val *string) {
+	doc, err := html.Parse(bytes.NewReader(contents))
+	if err != nil {
+		log.Trace().Err(err).Msg("Error parsing HTML to get title.")
+		return
+	}
+
+	return findTitle(doc)
+}
+
+func findTitle(doc *html.Node) *string {
 	h1Element := findFirstH1(doc)
-	if h1Element != nil {
+	if h1Element != nil && h1Element.FirstChild != nil {
 		return &h1Element.FirstChild.Data
 	} else {
 		return nil
@@ -52,3 +70,19 @@ func GetCreationTimeForFile(filePath string) (creationTime time.Time) {
 	}
 	return fileInfo.ModTime()
 }
+
+// Slugify turns a name into a lowercase, URL safe path segment, e.g. "Go & Rust" becomes "go-rust".
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-func WatchInputDirectory(config Config) error {
+// WatchInputDirectory rebuilds the site whenever an input changes and tells browsers
+// connected to reload about rebuilds and their errors. reload may be nil.
+func WatchInputDirectory(config Config, reload *LiveReload) error {
 	// Set up signal handling to stop the watcher gracefully
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
@@ -26,6 +28,9 @@ func WatchInputDirectory(config Config) error {
 
 	// Add the input directory to the watcher
 	watcherDirs := []string{config.ContentPath, config.StaticPath, config.TemplatesPath}
+	if config.DataPath != "" && fileExists(config.DataPath) {
+		watcherDirs = append(watcherDirs, config.DataPath)
+	}
 	for _, apexDir := range watcherDirs {
 		err := filepath.Walk(apexDir, func(watcherDir string, info os.FileInfo, err error) error {
 			if err != nil {
@@ -44,10 +49,18 @@ func WatchInputDirectory(config Config) error {
 		}
 	}
 
+	// Reuse one builder so unchanged templates aren't parsed again on every change
+	builder := NewBuilder(config)
+
 	// Initial conversion of files
-	err = ProcessFiles(config)
+	result, err := builder.Build()
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to process.")
+		if reload != nil {
+			reload.Fail(err)
+		}
+	} else if reload != nil {
+		reload.Notify(result)
 	}
 
 	log.Info().Msg("Watching input directory for changes.")
@@ -60,13 +73,27 @@ func WatchInputDirectory(config Config) error {
 				return errors.New("file watcher closed unexpectedly")
 			}
 
-			// Only trigger conversion on file modifications or creations
-			if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
+			// New directories need to be watched too, e.g. a freshly created blog section
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watcher.Add(event.Name); err != nil {
+						log.Error().Str("dir", event.Name).Err(err).Msg("Failed to add dir to watcher.")
+					}
+				}
+			}
+
+			// Trigger conversion on file modifications, creations and removals, the build only redoes what changed
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
 				log.Info().Str("changedFile", event.Name).Msg("File change detected, reloading.")
 
-				err = ProcessFiles(config)
+				result, err := builder.Build()
 				if err != nil {
 					log.Error().Err(err).Msg("Failed to refresh.")
+					if reload != nil {
+						reload.Fail(err)
+					}
+				} else if reload != nil {
+					reload.Notify(result)
 				}
 			}
 
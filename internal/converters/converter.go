@@ -0,0 +1,137 @@
+package converters
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Metadata holds information a converter was able to extract from a source file.
+type Metadata struct {
+	Title string
+	Link  string
+}
+
+// Result describes what a converter produced for a single source file. OutputPath is
+// empty when the converter extracted metadata but didn't write an HTML page. Assets lists
+// any other files written next to the page, like extracted media.
+type Result struct {
+	OutputPath string
+	Assets     []string
+	Metadata   Metadata
+}
+
+// Converter turns a source file into HTML. Implementations register themselves with
+// Register so the build can look them up by file extension.
+type Converter interface {
+	// Name identifies the converter in config.yaml.
+	Name() string
+	// Extensions lists the file extensions (including the leading dot) claimed by default.
+	Extensions() []string
+	// Convert reads inputPath and writes the HTML page to outputPath.
+	Convert(inputPath string, outputPath string) (Result, error)
+}
+
+// LinkConverter is implemented by converters of bookmark files. They don't write a page, the
+// link is returned in the metadata of the result instead, when ConvertsLinks reports true.
+type LinkConverter interface {
+	ConvertsLinks() bool
+}
+
+// FrontMatterConverter is implemented by converters of formats that can start with front
+// matter, which the build reads into the entry of the page.
+type FrontMatterConverter interface {
+	HasFrontMatter(inputPath string) bool
+}
+
+// ConversionError is returned when an external converter fails, carrying what it printed.
+type ConversionError struct {
+	Converter string
+	Output    string
+	Err       error
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Converter, e.Err)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	builtinMu sync.Mutex
+	builtin   []Converter
+)
+
+// Register adds a converter to the set every new Registry starts with.
+func Register(c Converter) {
+	builtinMu.Lock()
+	defer builtinMu.Unlock()
+	builtin = append(builtin, c)
+}
+
+// Registry maps file extensions onto converters.
+type Registry struct {
+	byName      map[string]Converter
+	byExtension map[string]Converter
+}
+
+// NewRegistry returns a registry with all registered converters and their default extensions.
+func NewRegistry() *Registry {
+	r := &Registry{
+		byName:      make(map[string]Converter),
+		byExtension: make(map[string]Converter),
+	}
+
+	builtinMu.Lock()
+	defer builtinMu.Unlock()
+	for _, c := range builtin {
+		r.Add(c)
+	}
+
+	return r
+}
+
+// Add registers a converter with this registry only, claiming its default extensions.
+func (r *Registry) Add(c Converter) {
+	r.byName[c.Name()] = c
+	for _, ext := range c.Extensions() {
+		r.byExtension[normalizeExtension(ext)] = c
+	}
+}
+
+// Map routes an extension to the converter with the given name.
+func (r *Registry) Map(extension string, name string) error {
+	c, ok := r.byName[name]
+	if !ok {
+		return fmt.Errorf("unknown converter %q for extension %q, available converters: %s", name, extension, strings.Join(r.Names(), ", "))
+	}
+	r.byExtension[normalizeExtension(extension)] = c
+	return nil
+}
+
+// Lookup returns the converter claiming the given extension.
+func (r *Registry) Lookup(extension string) (Converter, bool) {
+	c, ok := r.byExtension[normalizeExtension(extension)]
+	return c, ok
+}
+
+// Names lists the names of all converters in the registry.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.byName))
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func normalizeExtension(extension string) string {
+	extension = strings.ToLower(extension)
+	if !strings.HasPrefix(extension, ".") {
+		extension = "." + extension
+	}
+	return extension
+}
@@ -0,0 +1,51 @@
+package converters
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	Register(htmlConverter{})
+}
+
+// htmlConverter copies HTML sources as-is so they can still be templated.
+type htmlConverter struct{}
+
+func (htmlConverter) Name() string {
+	return "html"
+}
+
+func (htmlConverter) Extensions() []string {
+	return []string{".html"}
+}
+
+func (htmlConverter) Convert(inputPath string, outputPath string) (Result, error) {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		log.Error().Err(err).Str("output", outputPath).Msg("Failed to create output path.")
+		return Result{}, err
+	}
+
+	source, err := os.Open(inputPath)
+	if err != nil {
+		return Result{}, err
+	}
+	defer source.Close()
+
+	destination, err := os.Create(outputPath)
+	if err != nil {
+		return Result{}, err
+	}
+	defer destination.Close()
+
+	if _, err := io.Copy(destination, source); err != nil {
+		return Result{}, err
+	}
+
+	log.Trace().Str("input", inputPath).Str("output", outputPath).Msg("Copied HTML file.")
+
+	return Result{OutputPath: outputPath}, nil
+}
@@ -1,15 +1,61 @@
 package converters
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
-	"io"
+	"fmt"
 	"os"
-	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
 
 	"github.com/rs/zerolog/log"
 )
 
+// Shell Link (.lnk) parsing follows [MS-SHLLINK]:
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-shllink/
+
+const shortcutHeaderSize = 0x4C
+
+// shortcutCLSID is 00021401-0000-0000-C000-000000000046 as stored in the header.
+var shortcutCLSID = [16]byte{0x01, 0x14, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46}
+
+// LinkFlags
+const (
+	shortcutHasLinkTargetIDList = 1 << 0
+	shortcutHasLinkInfo         = 1 << 1
+	shortcutHasName             = 1 << 2
+	shortcutHasRelativePath     = 1 << 3
+	shortcutHasWorkingDir       = 1 << 4
+	shortcutHasArguments        = 1 << 5
+	shortcutHasIconLocation     = 1 << 6
+	shortcutIsUnicode           = 1 << 7
+)
+
+// LinkInfoFlags
+const (
+	shortcutVolumeIDAndLocalBasePath               = 1 << 0
+	shortcutCommonNetworkRelativeLinkAndPathSuffix = 1 << 1
+)
+
+// ExtraData block signatures
+const (
+	shortcutEnvironmentVariableBlock = 0xA0000001
+	shortcutConsoleBlock             = 0xA0000002
+	shortcutTrackerBlock             = 0xA0000003
+	shortcutConsoleFEBlock           = 0xA0000004
+	shortcutSpecialFolderBlock       = 0xA0000005
+	shortcutDarwinBlock              = 0xA0000006
+	shortcutIconEnvironmentBlock     = 0xA0000007
+	shortcutShimBlock                = 0xA0000008
+	shortcutPropertyStoreBlock       = 0xA0000009
+	shortcutKnownFolderBlock         = 0xA000000B
+	shortcutVistaIDListBlock         = 0xA000000C
+)
+
+var shortcutUrlPattern = regexp.MustCompile(`https?://[^\s"'<>\x00]+`)
+
 type ShortcutHeader struct {
 	HeaderSize     uint32
 	LinkCLSID      [16]byte
@@ -27,73 +73,375 @@ type ShortcutHeader struct {
 	Reserved3      uint32
 }
 
-func ExtractLinkFromShortcut(inputDir string, inputFileRelPath string) (string, error) {
-	inputDirAbs, err := filepath.Abs(inputDir)
-	if err != nil {
-		log.Error().Err(err).Str("inputDirAbs", inputDir).Msg("Failed to get input absolute path")
-		return "", err
+// ShortcutExtraData is an ExtraData block, Data excludes the size and signature.
+type ShortcutExtraData struct {
+	Signature uint32
+	Data      []byte
+}
+
+// Shortcut is a parsed Shell Link. Strings that aren't present in the file are empty.
+type Shortcut struct {
+	Header ShortcutHeader
+
+	// IDList holds the raw ItemIDs of the LinkTargetIDList.
+	IDList [][]byte
+
+	// LinkInfo
+	DriveType        uint32
+	DriveSerial      uint32
+	VolumeLabel      string
+	LocalBasePath    string
+	NetName          string
+	DeviceName       string
+	CommonPathSuffix string
+
+	// StringData
+	Name         string
+	RelativePath string
+	WorkingDir   string
+	Arguments    string
+	IconLocation string
+
+	// EnvironmentTarget is the target path from an EnvironmentVariableDataBlock, e.g. %windir%\notepad.exe.
+	EnvironmentTarget string
+	ExtraData         []ShortcutExtraData
+}
+
+// Target returns the path the shortcut points at, preferring the local path over the
+// network path and the environment variable path.
+func (s Shortcut) Target() string {
+	switch {
+	case s.LocalBasePath != "":
+		return s.LocalBasePath + s.CommonPathSuffix
+	case s.NetName != "":
+		if s.CommonPathSuffix == "" {
+			return s.NetName
+		}
+		return s.NetName + `\` + s.CommonPathSuffix
+	case s.EnvironmentTarget != "":
+		return s.EnvironmentTarget
 	}
-	inputFileAbsPath := filepath.Join(inputDirAbs, inputFileRelPath)
+	return s.RelativePath
+}
 
-	// Open the shortcut file
-	file, err := os.Open(inputFileAbsPath)
-	if err != nil {
-		return "", err
+// Url finds the web address a shortcut opens. Internet shortcuts store it in the target
+// ID list, shortcuts that launch a browser pass it as an argument.
+func (s Shortcut) Url() (string, bool) {
+	candidates := []string{s.Arguments, s.Target(), s.Name}
+	for _, item := range s.IDList {
+		if len(item) == 0 {
+			continue
+		}
+		// UTF-16 strings in shell items aren't necessarily aligned to the item
+		candidates = append(candidates, string(item), decodeUtf16Run(item), decodeUtf16Run(item[1:]))
+	}
+	for _, c := range candidates {
+		if url := shortcutUrlPattern.FindString(c); url != "" {
+			return url, true
+		}
 	}
-	defer file.Close()
+	return "", false
+}
+
+func init() {
+	Register(shortcutConverter{})
+}
+
+type shortcutConverter struct{}
+
+func (shortcutConverter) Name() string {
+	return "lnk"
+}
+
+func (shortcutConverter) Extensions() []string {
+	return []string{".lnk"}
+}
+
+func (shortcutConverter) ConvertsLinks() bool {
+	return true
+}
 
-	// Read the shortcut header
-	var header ShortcutHeader
-	err = binary.Read(file, binary.LittleEndian, &header)
+func (shortcutConverter) Convert(inputPath string, outputPath string) (Result, error) {
+	shortcut, err := ParseShortcut(inputPath)
 	if err != nil {
-		return "", err
+		log.Error().Err(err).Str("input", inputPath).Msg("Failed to parse lnk.")
+		return Result{}, err
 	}
 
-	// Check if the file is a valid Windows shortcut
-	if string(header.LinkCLSID[:]) != "{00021401-0000-0000-C000-000000000046}" {
-		return "", errors.New("not a valid Windows shortcut file")
+	link, ok := shortcut.Url()
+	if !ok {
+		// Shortcuts to local files are common and have nothing to publish
+		log.Warn().Str("input", inputPath).Str("target", shortcut.Target()).Msg("Skipping lnk since it doesn't point at a URL.")
+		return Result{}, nil
 	}
+	return Result{Metadata: Metadata{Link: link, Title: shortcut.Name}}, nil
+}
 
-	// Read the remaining data to extract the URL
-	remainingDataSize := header.HeaderSize - 76
-	remainingData := make([]byte, remainingDataSize)
-	_, err = io.ReadFull(file, remainingData)
+func ExtractLinkFromShortcut(inputPath string) (string, error) {
+	shortcut, err := ParseShortcut(inputPath)
 	if err != nil {
 		return "", err
 	}
 
-	// Find the URL prefix
-	urlPrefix := []byte("URL")
-	index := bytesIndex(remainingData, urlPrefix)
-	if index == -1 {
+	link, ok := shortcut.Url()
+	if !ok {
 		return "", errors.New("no URL found in the shortcut file")
 	}
+	return link, nil
+}
 
-	// Extract the URL
-	url := string(remainingData[index+4:])
+// ParseShortcut reads a Shell Link file.
+func ParseShortcut(inputPath string) (Shortcut, error) {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return Shortcut{}, err
+	}
+	return parseShortcut(data)
+}
+
+func parseShortcut(data []byte) (Shortcut, error) {
+	var s Shortcut
 
-	return url, nil
+	if len(data) < shortcutHeaderSize {
+		return s, errors.New("not a valid Windows shortcut file: too short")
+	}
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &s.Header); err != nil {
+		return s, err
+	}
+	if s.Header.HeaderSize != shortcutHeaderSize || s.Header.LinkCLSID != shortcutCLSID {
+		return s, errors.New("not a valid Windows shortcut file")
+	}
+	flags := s.Header.LinkFlags
+	off := shortcutHeaderSize
+
+	if flags&shortcutHasLinkTargetIDList != 0 {
+		size, ok := readUint16(data, off)
+		if !ok || off+2+int(size) > len(data) {
+			return s, errors.New("truncated LinkTargetIDList")
+		}
+		s.IDList = parseIDList(data[off+2 : off+2+int(size)])
+		off += 2 + int(size)
+	}
+
+	if flags&shortcutHasLinkInfo != 0 {
+		size, ok := readUint32(data, off)
+		if !ok || size < 0x1C || off+int(size) > len(data) {
+			return s, errors.New("truncated LinkInfo")
+		}
+		if err := s.parseLinkInfo(data[off : off+int(size)]); err != nil {
+			return s, err
+		}
+		off += int(size)
+	}
+
+	unicode := flags&shortcutIsUnicode != 0
+	for _, field := range []struct {
+		flag uint32
+		dst  *string
+	}{
+		{shortcutHasName, &s.Name},
+		{shortcutHasRelativePath, &s.RelativePath},
+		{shortcutHasWorkingDir, &s.WorkingDir},
+		{shortcutHasArguments, &s.Arguments},
+		{shortcutHasIconLocation, &s.IconLocation},
+	} {
+		if flags&field.flag == 0 {
+			continue
+		}
+		value, n, err := readCountedString(data, off, unicode)
+		if err != nil {
+			return s, err
+		}
+		*field.dst = value
+		off += n
+	}
+
+	// ExtraData runs until a terminal block smaller than 4 bytes or the end of the file
+	for {
+		size, ok := readUint32(data, off)
+		if !ok || size < 4 {
+			break
+		}
+		if size < 8 || off+int(size) > len(data) {
+			return s, errors.New("truncated ExtraData block")
+		}
+		signature, _ := readUint32(data, off+4)
+		block := ShortcutExtraData{Signature: signature, Data: data[off+8 : off+int(size)]}
+		s.ExtraData = append(s.ExtraData, block)
+
+		if signature == shortcutEnvironmentVariableBlock && len(block.Data) >= 260+520 {
+			s.EnvironmentTarget = decodeUtf16(block.Data[260 : 260+520])
+			if s.EnvironmentTarget == "" {
+				s.EnvironmentTarget = decodeAnsi(block.Data[:260])
+			}
+		}
+		off += int(size)
+	}
+
+	return s, nil
 }
 
-func bytesIndex(data []byte, substr []byte) int {
-	n := len(data)
-	m := len(substr)
-	for i := 0; i < n-m+1; i++ {
-		if bytesEqual(data[i:i+m], substr) {
-			return i
+func parseIDList(data []byte) [][]byte {
+	var items [][]byte
+	for off := 0; off+2 <= len(data); {
+		size, _ := readUint16(data, off)
+		if size < 2 || off+int(size) > len(data) {
+			break
 		}
+		items = append(items, data[off+2:off+int(size)])
+		off += int(size)
 	}
-	return -1
+	return items
 }
 
-func bytesEqual(a []byte, b []byte) bool {
-	if len(a) != len(b) {
-		return false
+func (s *Shortcut) parseLinkInfo(info []byte) error {
+	headerSize, _ := readUint32(info, 4)
+	flags, _ := readUint32(info, 8)
+	volumeIDOffset, _ := readUint32(info, 12)
+	localBasePathOffset, _ := readUint32(info, 16)
+	networkOffset, _ := readUint32(info, 20)
+	suffixOffset, _ := readUint32(info, 24)
+
+	// Newer writers append Unicode copies of the paths when the header is large enough
+	var localBasePathOffsetUnicode, suffixOffsetUnicode uint32
+	if headerSize >= 0x24 {
+		localBasePathOffsetUnicode, _ = readUint32(info, 28)
+		suffixOffsetUnicode, _ = readUint32(info, 32)
 	}
-	for i, v := range a {
-		if v != b[i] {
-			return false
+
+	if flags&shortcutVolumeIDAndLocalBasePath != 0 {
+		if err := s.parseVolumeID(info, int(volumeIDOffset)); err != nil {
+			return err
 		}
+		s.LocalBasePath = stringAt(info, localBasePathOffset, localBasePathOffsetUnicode)
 	}
-	return true
+
+	if flags&shortcutCommonNetworkRelativeLinkAndPathSuffix != 0 {
+		off := int(networkOffset)
+		size, ok := readUint32(info, off)
+		if !ok || size < 0x14 || off+int(size) > len(info) {
+			return errors.New("truncated CommonNetworkRelativeLink")
+		}
+		link := info[off : off+int(size)]
+		netNameOffset, _ := readUint32(link, 8)
+		deviceNameOffset, _ := readUint32(link, 12)
+		var netNameOffsetUnicode, deviceNameOffsetUnicode uint32
+		if netNameOffset > 0x14 {
+			netNameOffsetUnicode, _ = readUint32(link, 20)
+			deviceNameOffsetUnicode, _ = readUint32(link, 24)
+		}
+		s.NetName = stringAt(link, netNameOffset, netNameOffsetUnicode)
+		if linkFlags, _ := readUint32(link, 4); linkFlags&1 != 0 {
+			s.DeviceName = stringAt(link, deviceNameOffset, deviceNameOffsetUnicode)
+		}
+	}
+
+	s.CommonPathSuffix = stringAt(info, suffixOffset, suffixOffsetUnicode)
+	return nil
+}
+
+func (s *Shortcut) parseVolumeID(info []byte, off int) error {
+	size, ok := readUint32(info, off)
+	if !ok || size < 0x10 || off+int(size) > len(info) {
+		return errors.New("truncated VolumeID")
+	}
+	volume := info[off : off+int(size)]
+	s.DriveType, _ = readUint32(volume, 4)
+	s.DriveSerial, _ = readUint32(volume, 8)
+	labelOffset, _ := readUint32(volume, 12)
+	if labelOffset == 0x14 {
+		labelOffsetUnicode, _ := readUint32(volume, 16)
+		s.VolumeLabel = stringAt(volume, 0, labelOffsetUnicode)
+	} else {
+		s.VolumeLabel = stringAt(volume, labelOffset, 0)
+	}
+	return nil
+}
+
+// stringAt reads a NUL terminated string from data, preferring the Unicode copy when its
+// offset is set.
+func stringAt(data []byte, ansiOffset uint32, unicodeOffset uint32) string {
+	if unicodeOffset != 0 && int(unicodeOffset) < len(data) {
+		rest := data[unicodeOffset:]
+		for i := 0; i+1 < len(rest); i += 2 {
+			if rest[i] == 0 && rest[i+1] == 0 {
+				return decodeUtf16(rest[:i])
+			}
+		}
+		return decodeUtf16(rest)
+	}
+	if ansiOffset != 0 && int(ansiOffset) < len(data) {
+		return decodeAnsi(data[ansiOffset:])
+	}
+	return ""
+}
+
+// readCountedString reads a StringData entry, returning the string and the bytes it took.
+func readCountedString(data []byte, off int, unicode bool) (string, int, error) {
+	count, ok := readUint16(data, off)
+	if !ok {
+		return "", 0, errors.New("truncated StringData")
+	}
+	n := int(count)
+	if unicode {
+		n *= 2
+	}
+	if off+2+n > len(data) {
+		return "", 0, fmt.Errorf("truncated StringData at offset %d", off)
+	}
+	raw := data[off+2 : off+2+n]
+	if unicode {
+		return decodeUtf16(raw), 2 + n, nil
+	}
+	return decodeAnsi(raw), 2 + n, nil
+}
+
+func readUint16(data []byte, off int) (uint16, bool) {
+	if off < 0 || off+2 > len(data) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(data[off:]), true
+}
+
+func readUint32(data []byte, off int) (uint32, bool) {
+	if off < 0 || off+4 > len(data) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(data[off:]), true
+}
+
+// decodeUtf16 decodes little endian UTF-16 up to the first NUL.
+func decodeUtf16(data []byte) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		u := binary.LittleEndian.Uint16(data[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeUtf16Run decodes all of data as little endian UTF-16 without stopping at NULs, for
+// finding strings embedded in binary shell items.
+func decodeUtf16Run(data []byte) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, binary.LittleEndian.Uint16(data[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeAnsi decodes a string in the system code page up to the first NUL. The code page
+// isn't recorded in the file, so anything outside ASCII is read as Latin-1.
+func decodeAnsi(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	var b strings.Builder
+	for _, c := range data {
+		b.WriteRune(rune(c))
+	}
+	return b.String()
 }
@@ -0,0 +1,218 @@
+package converters
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The fixtures are written by testdata/lnk/generate.go.
+
+func TestParseShortcut(t *testing.T) {
+	tests := []struct {
+		file string
+
+		driveType        uint32
+		driveSerial      uint32
+		volumeLabel      string
+		localBasePath    string
+		netName          string
+		deviceName       string
+		commonPathSuffix string
+
+		name         string
+		relativePath string
+		workingDir   string
+		arguments    string
+		iconLocation string
+
+		environmentTarget string
+		extraData         []uint32
+		idListItems       int
+
+		target string
+		url    string
+	}{
+		{
+			file:          "local.lnk",
+			driveType:     3,
+			driveSerial:   0x307A8A81,
+			localBasePath: `C:\test\a.txt`,
+			relativePath:  `.\a.txt`,
+			workingDir:    `C:\test`,
+			extraData:     []uint32{shortcutTrackerBlock},
+			idListItems:   4,
+			target:        `C:\test\a.txt`,
+		},
+		{
+			file:             "network.lnk",
+			netName:          `\\FILESERVER\TEAM`,
+			deviceName:       "Z:",
+			commonPathSuffix: `docs\report.docx`,
+			name:             "Quarterly report",
+			workingDir:       `Z:\docs`,
+			extraData:        []uint32{shortcutTrackerBlock},
+			target:           `\\FILESERVER\TEAM\docs\report.docx`,
+		},
+		{
+			file:        "url.lnk",
+			name:        "Example page",
+			extraData:   []uint32{shortcutPropertyStoreBlock},
+			idListItems: 2,
+			url:         "https://example.com/from-lnk",
+		},
+		{
+			file:              "arguments.lnk",
+			driveType:         3,
+			driveSerial:       0x1C4E9A02,
+			volumeLabel:       "Windows",
+			localBasePath:     `C:\Program Files (x86)\Mozilla Firefox\firefox.exe`,
+			name:              "Go documentation",
+			workingDir:        `C:\Program Files (x86)\Mozilla Firefox`,
+			arguments:         `-new-tab "https://go.dev/doc/"`,
+			iconLocation:      `%ProgramFiles(x86)%\Mozilla Firefox\firefox.exe`,
+			environmentTarget: `%ProgramFiles(x86)%\Mozilla Firefox\firefox.exe`,
+			extraData: []uint32{
+				shortcutEnvironmentVariableBlock,
+				shortcutSpecialFolderBlock,
+				shortcutKnownFolderBlock,
+				shortcutTrackerBlock,
+				shortcutPropertyStoreBlock,
+			},
+			idListItems: 5,
+			target:      `C:\Program Files (x86)\Mozilla Firefox\firefox.exe`,
+			url:         "https://go.dev/doc/",
+		},
+		{
+			// The ANSI copy of the path reads C:\Users\Zoë\Documents\Résumé ???.docx
+			file:          "explorer.lnk",
+			driveType:     3,
+			driveSerial:   0x5A3C91E4,
+			localBasePath: `C:\Users\Zoë\Documents\Résumé 日本語.docx`,
+			name:          "Lebenslauf für Bewerbungen – 2024",
+			relativePath:  `..\Documents\Résumé 日本語.docx`,
+			workingDir:    `C:\Users\Zoë\Documents`,
+			extraData:     []uint32{shortcutTrackerBlock, shortcutPropertyStoreBlock},
+			idListItems:   6,
+			target:        `C:\Users\Zoë\Documents\Résumé 日本語.docx`,
+		},
+		{
+			file:              "environment.lnk",
+			relativePath:      `..\..\Windows\notepad.exe`,
+			environmentTarget: `%windir%\notepad.exe`,
+			extraData:         []uint32{shortcutEnvironmentVariableBlock},
+			target:            `%windir%\notepad.exe`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			s, err := ParseShortcut(filepath.Join("testdata", "lnk", tt.file))
+			if err != nil {
+				t.Fatalf("ParseShortcut() error = %v", err)
+			}
+
+			fields := []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"DriveType", s.DriveType, tt.driveType},
+				{"DriveSerial", s.DriveSerial, tt.driveSerial},
+				{"VolumeLabel", s.VolumeLabel, tt.volumeLabel},
+				{"LocalBasePath", s.LocalBasePath, tt.localBasePath},
+				{"NetName", s.NetName, tt.netName},
+				{"DeviceName", s.DeviceName, tt.deviceName},
+				{"CommonPathSuffix", s.CommonPathSuffix, tt.commonPathSuffix},
+				{"Name", s.Name, tt.name},
+				{"RelativePath", s.RelativePath, tt.relativePath},
+				{"WorkingDir", s.WorkingDir, tt.workingDir},
+				{"Arguments", s.Arguments, tt.arguments},
+				{"IconLocation", s.IconLocation, tt.iconLocation},
+				{"EnvironmentTarget", s.EnvironmentTarget, tt.environmentTarget},
+				{"len(IDList)", len(s.IDList), tt.idListItems},
+				{"Target()", s.Target(), tt.target},
+			}
+			for _, f := range fields {
+				if !reflect.DeepEqual(f.got, f.want) {
+					t.Errorf("%s = %#v, want %#v", f.name, f.got, f.want)
+				}
+			}
+
+			var signatures []uint32
+			for _, block := range s.ExtraData {
+				signatures = append(signatures, block.Signature)
+			}
+			if !reflect.DeepEqual(signatures, tt.extraData) {
+				t.Errorf("ExtraData signatures = %#x, want %#x", signatures, tt.extraData)
+			}
+
+			url, ok := s.Url()
+			if url != tt.url || ok != (tt.url != "") {
+				t.Errorf("Url() = %q, %v, want %q", url, ok, tt.url)
+			}
+		})
+	}
+}
+
+func TestShortcutConverter(t *testing.T) {
+	tests := []struct {
+		file  string
+		link  string
+		title string
+	}{
+		{file: "url.lnk", link: "https://example.com/from-lnk", title: "Example page"},
+		{file: "arguments.lnk", link: "https://go.dev/doc/", title: "Go documentation"},
+		// Shortcuts to files are skipped without an error
+		{file: "local.lnk"},
+		{file: "network.lnk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			result, err := shortcutConverter{}.Convert(filepath.Join("testdata", "lnk", tt.file), "")
+			if err != nil {
+				t.Fatalf("Convert() error = %v", err)
+			}
+			if result.OutputPath != "" {
+				t.Errorf("OutputPath = %q, want none", result.OutputPath)
+			}
+			if result.Metadata.Link != tt.link || result.Metadata.Title != tt.title {
+				t.Errorf("Metadata = %+v, want link %q and title %q", result.Metadata, tt.link, tt.title)
+			}
+		})
+	}
+}
+
+func TestParseShortcutMalformed(t *testing.T) {
+	valid, err := os.ReadFile(filepath.Join("testdata", "lnk", "arguments.lnk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	badCLSID := append([]byte{}, valid...)
+	badCLSID[4] ^= 0xFF
+
+	// The ExtraData of arguments.lnk takes the last 0x3B0 bytes
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "too short"},
+		{"partial header", valid[:0x40], "too short"},
+		{"wrong CLSID", badCLSID, "not a valid Windows shortcut file"},
+		{"truncated ID list", valid[:0x60], "truncated LinkTargetIDList"},
+		{"truncated string data", valid[:len(valid)-0x3B0-10], "truncated StringData"},
+		{"truncated extra data", valid[:len(valid)-0x20], "truncated ExtraData block"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseShortcut(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseShortcut() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
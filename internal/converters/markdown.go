@@ -0,0 +1,101 @@
+package converters
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/adrg/frontmatter"
+	"github.com/rs/zerolog/log"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+func init() {
+	Register(markdownConverter{})
+}
+
+// markdown renders GitHub Flavored Markdown, matching what `pandoc -f gfm` produced for
+// typical documents: tables, strikethrough, task lists, autolinks, footnotes and heading IDs.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		extension.Footnote,
+	),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+	),
+	goldmark.WithRendererOptions(
+		html.WithUnsafe(), // raw HTML is passed through like pandoc's gfm reader does
+	),
+)
+
+// markdownConverter renders markdown natively without spawning pandoc.
+type markdownConverter struct{}
+
+func (markdownConverter) Name() string {
+	return "markdown"
+}
+
+func (markdownConverter) Extensions() []string {
+	return []string{".md"}
+}
+
+func (markdownConverter) HasFrontMatter(inputPath string) bool {
+	return true
+}
+
+func (markdownConverter) Convert(inputPath string, outputPath string) (Result, error) {
+	outputPath, err := ConvertMarkdownToHTML(inputPath, outputPath)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{OutputPath: outputPath}, nil
+}
+
+func ConvertMarkdownToHTML(inputPath string, outputPath string) (string, error) {
+	source, err := os.ReadFile(inputPath)
+	if err != nil {
+		log.Error().Err(err).Str("input", inputPath).Msg("Failed to read markdown file.")
+		return "", err
+	}
+
+	// Front matter is consumed by the build, so it shouldn't end up in the page body
+	var fm map[string]interface{}
+	body, err := frontmatter.Parse(bytes.NewReader(source), &fm)
+	if err != nil {
+		log.Trace().Err(err).Str("input", inputPath).Msg("Failed to parse front matter, rendering the whole file.")
+		body = source
+	}
+
+	var buf bytes.Buffer
+	if err := markdown.Convert(body, &buf); err != nil {
+		log.Error().Err(err).Str("input", inputPath).Msg("Failed to render markdown.")
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		log.Error().Err(err).Str("output", outputPath).Msg("Failed to create output path.")
+		return "", err
+	}
+
+	if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+		log.Error().Err(err).Str("output", outputPath).Msg("Failed to write HTML file.")
+		return "", err
+	}
+
+	log.Trace().Str("input", inputPath).Str("output", outputPath).Msg("Converted markdown to HTML.")
+
+	return outputPath, nil
+}
+
+// RenderMarkdown renders a markdown snippet to HTML the same way .md pages are rendered.
+func RenderMarkdown(source []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert(source, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,458 @@
+package converters
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v2"
+)
+
+// DocumentMetadata is what a document says about itself, the native equivalent of markdown
+// front matter. Empty fields weren't found.
+type DocumentMetadata struct {
+	Title       string
+	Description string
+	CreatedAt   time.Time
+	Tags        []string
+	// Metadata holds any other fields, like the author, with the types they were stored with.
+	Metadata map[string]interface{}
+}
+
+// MetadataExtractor is implemented by converters that can read the metadata of the
+// documents they convert.
+type MetadataExtractor interface {
+	ExtractMetadata(inputPath string) (DocumentMetadata, error)
+}
+
+// ExtractMetadata reads the metadata stored in the documents pandoc converts.
+func (pandocConverter) ExtractMetadata(inputPath string) (DocumentMetadata, error) {
+	switch strings.ToLower(filepath.Ext(inputPath)) {
+	case ".docx":
+		return extractDocxMetadata(inputPath)
+	case ".odt":
+		return extractOdtMetadata(inputPath)
+	case ".ipynb":
+		return extractNotebookMetadata(inputPath)
+	case ".rst":
+		return extractRstMetadata(inputPath)
+	case ".txt":
+		return extractYamlHeaderMetadata(inputPath)
+	}
+	return DocumentMetadata{}, nil
+}
+
+// ExtractMetadata reads the <title> and <meta> tags of an HTML page.
+func (htmlConverter) ExtractMetadata(inputPath string) (DocumentMetadata, error) {
+	return extractHtmlMetadata(inputPath)
+}
+
+// metadataFieldKeys lists the field names recognized for each DocumentMetadata field, most
+// specific first.
+var metadataFieldKeys = map[string][]string{
+	"title":       {"title"},
+	"description": {"description", "summary", "abstract", "subject"},
+	"date":        {"date", "created_at", "created", "creation-date"},
+	"tags":        {"tags", "keywords"},
+}
+
+// metadataFromFields maps loosely named fields, like the ones in notebook metadata or rst
+// field lists, onto DocumentMetadata.
+func metadataFromFields(fields map[string]interface{}) DocumentMetadata {
+	lower := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		lower[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+
+	var m DocumentMetadata
+	known := make(map[string]bool)
+	first := func(field string) interface{} {
+		for _, key := range metadataFieldKeys[field] {
+			known[key] = true
+		}
+		for _, key := range metadataFieldKeys[field] {
+			if value, ok := lower[key]; ok && stringify(value) != "" {
+				return value
+			}
+		}
+		return nil
+	}
+
+	m.Title = stringify(first("title"))
+	m.Description = stringify(first("description"))
+	switch date := first("date").(type) {
+	case time.Time:
+		m.CreatedAt = date
+	case nil:
+	default:
+		m.CreatedAt = parseMetadataDate(stringify(date))
+	}
+	for _, key := range metadataFieldKeys["tags"] {
+		known[key] = true
+		m.Tags = append(m.Tags, splitKeywords(lower[key])...)
+	}
+
+	for key, value := range lower {
+		if known[key] {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			value = strings.TrimSpace(s)
+		}
+		if value == nil || value == "" {
+			continue
+		}
+		if m.Metadata == nil {
+			m.Metadata = make(map[string]interface{})
+		}
+		m.Metadata[key] = value
+	}
+	return m
+}
+
+// stringify renders scalars and lists of scalars, anything else is dropped.
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			if s := stringify(item); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, ", ")
+	case map[string]interface{}, map[interface{}]interface{}:
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// splitKeywords accepts a list or a comma or semicolon separated string.
+func splitKeywords(value interface{}) []string {
+	var raw []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			raw = append(raw, stringify(item))
+		}
+	case []string:
+		raw = v
+	case nil:
+		return nil
+	default:
+		raw = strings.FieldsFunc(stringify(v), func(r rune) bool { return r == ',' || r == ';' })
+	}
+
+	var tags []string
+	for _, tag := range raw {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+var metadataDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"January 2, 2006",
+	"2 January 2006",
+}
+
+func parseMetadataDate(s string) time.Time {
+	for _, layout := range metadataDateLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// readZipEntry reads a single file out of an office document.
+func readZipEntry(inputPath string, name string) ([]byte, error) {
+	archive, err := zip.OpenReader(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, nil
+}
+
+// extractDocxMetadata reads the core properties of a Word document, docProps/core.xml.
+func extractDocxMetadata(inputPath string) (DocumentMetadata, error) {
+	data, err := readZipEntry(inputPath, "docProps/core.xml")
+	if err != nil || data == nil {
+		return DocumentMetadata{}, err
+	}
+
+	var core struct {
+		Title       string `xml:"title"`
+		Subject     string `xml:"subject"`
+		Description string `xml:"description"`
+		Keywords    string `xml:"keywords"`
+		Creator     string `xml:"creator"`
+		Category    string `xml:"category"`
+		Created     string `xml:"created"`
+	}
+	if err := xml.Unmarshal(data, &core); err != nil {
+		return DocumentMetadata{}, err
+	}
+
+	return metadataFromFields(map[string]interface{}{
+		"title":    core.Title,
+		"subject":  core.Subject,
+		"summary":  core.Description,
+		"keywords": core.Keywords,
+		"author":   core.Creator,
+		"category": core.Category,
+		"created":  core.Created,
+	}), nil
+}
+
+// extractOdtMetadata reads the document metadata of an OpenDocument text, meta.xml.
+func extractOdtMetadata(inputPath string) (DocumentMetadata, error) {
+	data, err := readZipEntry(inputPath, "meta.xml")
+	if err != nil || data == nil {
+		return DocumentMetadata{}, err
+	}
+
+	var doc struct {
+		Meta struct {
+			Title          string   `xml:"title"`
+			Subject        string   `xml:"subject"`
+			Description    string   `xml:"description"`
+			Keywords       []string `xml:"keyword"`
+			InitialCreator string   `xml:"initial-creator"`
+			CreationDate   string   `xml:"creation-date"`
+		} `xml:"meta"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return DocumentMetadata{}, err
+	}
+
+	keywords := make([]interface{}, 0, len(doc.Meta.Keywords))
+	for _, k := range doc.Meta.Keywords {
+		keywords = append(keywords, k)
+	}
+	return metadataFromFields(map[string]interface{}{
+		"title":    doc.Meta.Title,
+		"subject":  doc.Meta.Subject,
+		"summary":  doc.Meta.Description,
+		"keywords": keywords,
+		"author":   doc.Meta.InitialCreator,
+		"created":  doc.Meta.CreationDate,
+	}), nil
+}
+
+// extractNotebookMetadata reads the notebook level metadata of a Jupyter notebook. Authors
+// are stored as [{"name": ...}] by convention.
+func extractNotebookMetadata(inputPath string) (DocumentMetadata, error) {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return DocumentMetadata{}, err
+	}
+
+	var notebook struct {
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &notebook); err != nil {
+		return DocumentMetadata{}, err
+	}
+
+	fields := make(map[string]interface{})
+	for key, value := range notebook.Metadata {
+		switch key {
+		case "kernelspec", "language_info", "widgets":
+			// Execution details, not something to publish
+		case "authors":
+			var names []interface{}
+			if authors, ok := value.([]interface{}); ok {
+				for _, a := range authors {
+					if author, ok := a.(map[string]interface{}); ok {
+						names = append(names, author["name"])
+					} else {
+						names = append(names, a)
+					}
+				}
+			}
+			fields["author"] = names
+		default:
+			fields[key] = value
+		}
+	}
+	return metadataFromFields(fields), nil
+}
+
+var rstFieldPattern = regexp.MustCompile(`^:([^:]+):\s*(.*)$`)
+
+// isRstAdornment reports whether line under- or overlines a section title, e.g. =====.
+func isRstAdornment(line string) bool {
+	if len(line) < 2 || !strings.ContainsRune("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// extractRstMetadata reads the document title and the bibliographic field list following
+// it, like :Date: and :Tags:.
+func extractRstMetadata(inputPath string) (DocumentMetadata, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return DocumentMetadata{}, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), " \t\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return DocumentMetadata{}, err
+	}
+
+	i := 0
+	skipBlank := func() {
+		for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+			i++
+		}
+	}
+
+	// The title is a line underlined, and optionally overlined, by punctuation
+	title := ""
+	skipBlank()
+	if i+1 < len(lines) && isRstAdornment(lines[i]) && i+2 < len(lines) && isRstAdornment(lines[i+2]) {
+		title = strings.TrimSpace(lines[i+1])
+		i += 3
+	} else if i+1 < len(lines) && !isRstAdornment(lines[i]) && isRstAdornment(lines[i+1]) {
+		title = strings.TrimSpace(lines[i])
+		i += 2
+	}
+
+	fields := make(map[string]interface{})
+	skipBlank()
+	for i < len(lines) {
+		match := rstFieldPattern.FindStringSubmatch(lines[i])
+		if match == nil {
+			break
+		}
+		value := match[2]
+		i++
+		// Field bodies continue on indented lines
+		for i < len(lines) && lines[i] != "" && (lines[i][0] == ' ' || lines[i][0] == '\t') {
+			value += " " + strings.TrimSpace(lines[i])
+			i++
+		}
+		fields[match[1]] = value
+	}
+
+	m := metadataFromFields(fields)
+	if m.Title == "" {
+		m.Title = title
+	}
+	return m, nil
+}
+
+// extractYamlHeaderMetadata reads a YAML block at the top of a plain text file, delimited by
+// --- and either --- or ... like pandoc's metadata blocks.
+func extractYamlHeaderMetadata(inputPath string) (DocumentMetadata, error) {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return DocumentMetadata{}, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return DocumentMetadata{}, nil
+	}
+	for end := 1; end < len(lines); end++ {
+		if line := strings.TrimSpace(lines[end]); line == "---" || line == "..." {
+			var fields map[string]interface{}
+			if err := yaml.Unmarshal([]byte(strings.Join(lines[1:end], "\n")), &fields); err != nil {
+				return DocumentMetadata{}, err
+			}
+			return metadataFromFields(fields), nil
+		}
+	}
+	return DocumentMetadata{}, nil
+}
+
+// extractHtmlMetadata reads the <title> and the named <meta> tags of the page's head.
+func extractHtmlMetadata(inputPath string) (DocumentMetadata, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return DocumentMetadata{}, err
+	}
+	defer file.Close()
+
+	doc, err := html.Parse(file)
+	if err != nil {
+		return DocumentMetadata{}, err
+	}
+
+	fields := make(map[string]interface{})
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "body":
+				return
+			case "title":
+				if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+					fields["title"] = n.FirstChild.Data
+				}
+			case "meta":
+				var name, content string
+				for _, attr := range n.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "name", "property":
+						name = attr.Val
+					case "content":
+						content = attr.Val
+					}
+				}
+				// Generators and viewports say nothing about the page
+				if name != "" && content != "" && name != "viewport" && name != "generator" {
+					fields[name] = content
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return metadataFromFields(fields), nil
+}
@@ -0,0 +1,113 @@
+package converters
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The .docx and .odt fixtures are written by testdata/metadata/generate.go.
+
+func TestExtractMetadata(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		file      string
+		extractor MetadataExtractor
+		want      DocumentMetadata
+	}{
+		{
+			file:      "report.docx",
+			extractor: pandocConverter{},
+			want: DocumentMetadata{
+				Title:       "Quarterly report",
+				Description: "Numbers of the first quarter",
+				CreatedAt:   time.Date(2024, time.February, 10, 9, 15, 0, 0, time.UTC),
+				Tags:        []string{"finance", "reports"},
+				Metadata:    map[string]interface{}{"author": "Ada Lovelace"},
+			},
+		},
+		{file: "no-core.docx", extractor: pandocConverter{}},
+		{
+			file:      "notes.odt",
+			extractor: pandocConverter{},
+			want: DocumentMetadata{
+				Title:       "Meeting notes",
+				Description: "Planning",
+				CreatedAt:   time.Date(2024, time.January, 5, 10, 0, 0, 123456789, time.UTC),
+				Tags:        []string{"meetings", "planning"},
+				Metadata:    map[string]interface{}{"author": "Grace Hopper"},
+			},
+		},
+		{file: "no-meta.odt", extractor: pandocConverter{}},
+		{
+			file:      "notebook.ipynb",
+			extractor: pandocConverter{},
+			want: DocumentMetadata{
+				Title:       "Fitting a line",
+				Description: "Least squares in a few lines",
+				CreatedAt:   date(2024, time.April, 2),
+				Tags:        []string{"python", "statistics"},
+				Metadata:    map[string]interface{}{"author": []interface{}{"Ada Lovelace", "Charles Babbage"}},
+			},
+		},
+		{file: "empty.ipynb", extractor: pandocConverter{}},
+		{
+			file:      "titled.rst",
+			extractor: pandocConverter{},
+			want: DocumentMetadata{
+				Title:       "Writing in rst",
+				Description: "Why field lists are handy.",
+				CreatedAt:   date(2024, time.March, 1),
+				Tags:        []string{"docs", "rst"},
+				Metadata:    map[string]interface{}{"author": "Ada Lovelace"},
+			},
+		},
+		{
+			file:      "fields.rst",
+			extractor: pandocConverter{},
+			want: DocumentMetadata{
+				CreatedAt: date(2024, time.March, 2),
+				Tags:      []string{"notes"},
+				Metadata:  map[string]interface{}{"status": "draft"},
+			},
+		},
+		{
+			file:      "header.txt",
+			extractor: pandocConverter{},
+			want: DocumentMetadata{
+				Title:       "Plain notes",
+				Description: "Written in a text editor",
+				CreatedAt:   date(2024, time.May, 1),
+				Tags:        []string{"text", "notes"},
+				Metadata:    map[string]interface{}{"weight": 3},
+			},
+		},
+		{file: "plain.txt", extractor: pandocConverter{}},
+		{
+			file:      "page.html",
+			extractor: htmlConverter{},
+			want: DocumentMetadata{
+				Title:       "Hand written page",
+				Description: "Written without a generator",
+				CreatedAt:   date(2024, time.June, 1),
+				Tags:        []string{"html", "handmade"},
+				Metadata:    map[string]interface{}{"og:type": "article"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := tt.extractor.ExtractMetadata(filepath.Join("testdata", "metadata", tt.file))
+			if err != nil {
+				t.Fatalf("ExtractMetadata() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractMetadata() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,61 +4,132 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 )
 
-func ConvertFileToHTML(inputDir string, inputFileRelPath string, outputDir string, outputFileName string) (string, error) {
-	outputFileName = outputFileName + ".html"
+func init() {
+	Register(pandocConverter{})
+}
+
+type pandocConverter struct{}
+
+func (pandocConverter) Name() string {
+	return "pandoc"
+}
+
+func (pandocConverter) Extensions() []string {
+	// Markdown is rendered natively by default, see markdown.go.
+	return []string{".docx", ".rtf", ".odt", ".txt", ".rst", ".ipynb"}
+}
+
+// pandocMarkdownExtensions are the extensions pandoc reads as markdown, see formatFromFilePath
+// in pandoc's FormatHeuristics.hs. Plain text (.txt and .text) is left out, its YAML header is
+// read by extractYamlHeaderMetadata instead.
+var pandocMarkdownExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".mdown":    true,
+	".mdwn":     true,
+	".mkd":      true,
+	".mkdn":     true,
+}
+
+// pandocInputFormat returns the format pandoc reads the file as, gfm for markdown, or "" to
+// leave it to pandoc.
+func pandocInputFormat(inputPath string) string {
+	if pandocMarkdownExtensions[strings.ToLower(filepath.Ext(inputPath))] {
+		return "gfm"
+	}
+	return ""
+}
 
-	// Get absolute path of input directory
-	inputDirAbs, err := filepath.Abs(inputDir)
+// HasFrontMatter reports whether pandoc reads the file as markdown, which starts with front
+// matter just like it does for the markdown converter.
+func (pandocConverter) HasFrontMatter(inputPath string) bool {
+	return pandocInputFormat(inputPath) == "gfm"
+}
+
+func (pandocConverter) Convert(inputPath string, outputPath string) (Result, error) {
+	outputPath, err := ConvertFileToHTML(inputPath, outputPath)
 	if err != nil {
-		log.Error().Err(err).Str("inputDirAbs", inputDir).Msg("Failed to get input absolute path.")
-		return "", err
+		return Result{}, err
 	}
 
-	// Get absolute path of output directory
-	outputDirAbs, err := filepath.Abs(outputDir)
+	assets, err := findExtractedMedia(outputPath)
 	if err != nil {
-		log.Error().Err(err).Str("outputDirAbs", outputDir).Msg("Failed to get output absolute path.")
-		return "", err
+		log.Warn().Err(err).Str("output", outputPath).Msg("Failed to find media extracted by pandoc.")
 	}
 
-	// Create the output directory structure
-	outputPath := filepath.Join(outputDir, filepath.Dir(inputFileRelPath))
-	if err := os.MkdirAll(outputPath, 0755); err != nil {
-		log.Error().Err(err).Str("outputPath", outputPath).Msg("Failed to create output path.")
-		return "", err
+	return Result{OutputPath: outputPath, Assets: assets}, nil
+}
+
+// findExtractedMedia lists the files under _assets/ referenced by the page pandoc wrote.
+func findExtractedMedia(outputPath string) ([]string, error) {
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var assets []string
+	for _, quote := range []string{`"`, `'`} {
+		rest := string(data)
+		prefix := quote + "_assets/"
+		for {
+			i := strings.Index(rest, prefix)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(quote):]
+			end := strings.Index(rest, quote)
+			if end < 0 {
+				break
+			}
+			asset := filepath.Join(filepath.Dir(outputPath), filepath.FromSlash(rest[:end]))
+			if _, err := os.Stat(asset); err == nil && !seen[asset] {
+				seen[asset] = true
+				assets = append(assets, asset)
+			}
+			rest = rest[end:]
+		}
 	}
+	return assets, nil
+}
 
-	assetsPath := filepath.Join(outputDirAbs, filepath.Dir("_assets"))
-	if err := os.MkdirAll(assetsPath, 0755); err != nil {
-		log.Error().Err(err).Str("assetsPath", assetsPath).Msg("Failed to create assets path.")
+func ConvertFileToHTML(inputPath string, outputPath string) (string, error) {
+	// Get absolute path of input file
+	inputFileAbsPath, err := filepath.Abs(inputPath)
+	if err != nil {
+		log.Error().Err(err).Str("inputPath", inputPath).Msg("Failed to get input absolute path.")
 		return "", err
 	}
 
-	// Construct the output file path
-	inputFileAbsPath := filepath.Join(inputDirAbs, inputFileRelPath)
-	outputFileRelPath := filepath.Join(filepath.Dir(inputFileRelPath), outputFileName)
+	// Create the output directory structure
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		log.Error().Err(err).Str("outputDir", outputDir).Msg("Failed to create output path.")
+		return "", err
+	}
 
 	args := []string{}
-	if filepath.Ext(inputFileRelPath) == ".md" {
-		log.Trace().Msg("Detected markdown, using raw_html extension.")
-		args = append(args, "-f", "gfm")
+	if format := pandocInputFormat(inputPath); format != "" {
+		log.Trace().Str("format", format).Msg("Detected markdown, using raw_html extension.")
+		args = append(args, "-f", format)
 	}
-	args = append(args, inputFileAbsPath, "-o", outputFileName, "-t", "html", "--extract-media=_assets")
+	args = append(args, inputFileAbsPath, "-o", filepath.Base(outputPath), "-t", "html", "--extract-media=_assets")
 
 	// Run the pandoc command to convert the file to HTML
 	cmd := exec.Command("pandoc", args...)
-	cmd.Dir = filepath.Join(outputDir, filepath.Dir(inputFileRelPath))
+	cmd.Dir = outputDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		log.Error().Err(err).Str("input", inputFileAbsPath).Str("output", outputFileRelPath).Bytes("stdout/stderr", out).Msg("Failed to convert file to HTML with Pandoc.")
-		return "", err
+		log.Error().Err(err).Str("input", inputFileAbsPath).Str("output", outputPath).Bytes("stdout/stderr", out).Msg("Failed to convert file to HTML with Pandoc.")
+		return "", &ConversionError{Converter: "pandoc", Output: string(out), Err: err}
 	}
 
-	log.Trace().Str("input", inputFileAbsPath).Str("output", outputFileRelPath).Msg("Converted file to HTML.")
+	log.Trace().Str("input", inputFileAbsPath).Str("output", outputPath).Msg("Converted file to HTML.")
 
-	return filepath.Join(outputDir, outputFileRelPath), nil
+	return outputPath, nil
 }
@@ -0,0 +1,24 @@
+package converters
+
+import "testing"
+
+func TestPandocHasFrontMatter(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"post.md", true},
+		{"post.markdown", true},
+		{"post.MKD", true},
+		{"notes/post.mdown", true},
+		{"notes.txt", false},
+		{"report.docx", false},
+		{"guide.rst", false},
+		{"README", false},
+	}
+	for _, tt := range tests {
+		if got := (pandocConverter{}).HasFrontMatter(tt.path); got != tt.want {
+			t.Errorf("HasFrontMatter(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
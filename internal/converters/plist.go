@@ -0,0 +1,377 @@
+package converters
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Property lists are decoded into map[string]interface{}, []interface{}, string, int64,
+// uint64 (UIDs), float64, bool, []byte and time.Time values.
+
+const binaryPlistMagic = "bplist00"
+
+// plistEpoch is the reference date of binary plist dates.
+var plistEpoch = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// DecodePlist decodes an XML or binary (bplist00) property list and returns its top object.
+func DecodePlist(data []byte) (interface{}, error) {
+	if bytes.HasPrefix(data, []byte(binaryPlistMagic)) {
+		return decodeBinaryPlist(data)
+	}
+	return decodeXmlPlist(data)
+}
+
+func decodeXmlPlist(data []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// Plists are UTF-8 in practice, don't trip over declarations of other encodings
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("plist has no value")
+			}
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local == "plist" {
+			continue
+		}
+		return decodeXmlPlistValue(decoder, start)
+	}
+}
+
+func decodeXmlPlistValue(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]interface{})
+		var key *string
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "key" {
+					var k string
+					if err := decoder.DecodeElement(&k, &t); err != nil {
+						return nil, err
+					}
+					key = &k
+					continue
+				}
+				value, err := decodeXmlPlistValue(decoder, t)
+				if err != nil {
+					return nil, err
+				}
+				if key == nil {
+					return nil, fmt.Errorf("plist dict has a <%s> without a key", t.Name.Local)
+				}
+				dict[*key] = value
+				key = nil
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+
+	case "array":
+		array := []interface{}{}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				value, err := decodeXmlPlistValue(decoder, t)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			case xml.EndElement:
+				return array, nil
+			}
+		}
+
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	var text string
+	if err := decoder.DecodeElement(&text, &start); err != nil {
+		return nil, err
+	}
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	case "real":
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case "date":
+		return time.Parse(time.RFC3339, strings.TrimSpace(text))
+	case "data":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	}
+	return nil, fmt.Errorf("unknown plist element <%s>", start.Name.Local)
+}
+
+// binaryPlist decodes the objects of a bplist00 file, see CFBinaryPList.c for the format.
+type binaryPlist struct {
+	data          []byte
+	offsets       []uint64
+	objectRefSize int
+	decoding      map[uint64]bool
+	// decoded holds the objects decoded so far, objects referenced from several containers
+	// are only decoded once
+	decoded map[uint64]interface{}
+}
+
+func decodeBinaryPlist(data []byte) (interface{}, error) {
+	if len(data) < len(binaryPlistMagic)+32 {
+		return nil, errors.New("binary plist is too short")
+	}
+	trailer := data[len(data)-32:]
+	offsetIntSize := int(trailer[6])
+	objectRefSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	topObject := binary.BigEndian.Uint64(trailer[16:])
+	offsetTableOffset := binary.BigEndian.Uint64(trailer[24:])
+
+	if offsetIntSize < 1 || offsetIntSize > 8 || objectRefSize < 1 || objectRefSize > 8 {
+		return nil, errors.New("binary plist has an invalid trailer")
+	}
+	tableEnd := uint64(len(data) - 32)
+	if numObjects == 0 || offsetTableOffset > tableEnd || numObjects > (tableEnd-offsetTableOffset)/uint64(offsetIntSize) {
+		return nil, errors.New("binary plist has an invalid offset table")
+	}
+
+	p := &binaryPlist{
+		data:          data,
+		offsets:       make([]uint64, numObjects),
+		objectRefSize: objectRefSize,
+		decoding:      make(map[uint64]bool),
+		decoded:       make(map[uint64]interface{}),
+	}
+	for i := range p.offsets {
+		start := offsetTableOffset + uint64(i*offsetIntSize)
+		p.offsets[i] = readBigEndian(data[start : start+uint64(offsetIntSize)])
+	}
+
+	return p.object(topObject)
+}
+
+func (p *binaryPlist) object(ref uint64) (interface{}, error) {
+	if ref >= uint64(len(p.offsets)) {
+		return nil, fmt.Errorf("binary plist references missing object %d", ref)
+	}
+	if value, ok := p.decoded[ref]; ok {
+		return value, nil
+	}
+	value, err := p.decodeObject(ref)
+	if err != nil {
+		return nil, err
+	}
+	p.decoded[ref] = value
+	return value, nil
+}
+
+// decodeObject decodes the object ref, decoding the objects it references through object.
+func (p *binaryPlist) decodeObject(ref uint64) (interface{}, error) {
+	// Containers can reference each other, don't follow a cycle forever
+	if p.decoding[ref] {
+		return nil, fmt.Errorf("binary plist object %d references itself", ref)
+	}
+	p.decoding[ref] = true
+	defer delete(p.decoding, ref)
+
+	off := p.offsets[ref]
+	if off >= uint64(len(p.data)) {
+		return nil, fmt.Errorf("binary plist object %d is out of bounds", ref)
+	}
+	marker := p.data[off]
+	kind, info := marker>>4, marker&0x0F
+	off++
+
+	switch kind {
+	case 0x0:
+		switch info {
+		case 0x0:
+			return nil, nil
+		case 0x8:
+			return false, nil
+		case 0x9:
+			return true, nil
+		}
+		return nil, fmt.Errorf("binary plist object %d has unknown marker %#x", ref, marker)
+
+	case 0x1:
+		raw, err := p.bytes(off, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) == 16 {
+			// 128 bit integers only exist to hold negative 64 bit values
+			raw = raw[8:]
+		}
+		return int64(readBigEndian(raw)), nil
+
+	case 0x2:
+		raw, err := p.bytes(off, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+		switch len(raw) {
+		case 4:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), nil
+		case 8:
+			return math.Float64frombits(binary.BigEndian.Uint64(raw)), nil
+		}
+		return nil, fmt.Errorf("binary plist object %d is a %d byte real", ref, len(raw))
+
+	case 0x3:
+		raw, err := p.bytes(off, 8)
+		if err != nil {
+			return nil, err
+		}
+		seconds := math.Float64frombits(binary.BigEndian.Uint64(raw))
+		return plistEpoch.Add(time.Duration(seconds * float64(time.Second))), nil
+
+	case 0x4, 0x5, 0x6:
+		count, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		size := count
+		if kind == 0x6 {
+			size *= 2
+		}
+		raw, err := p.bytes(off, size)
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case 0x4:
+			return append([]byte{}, raw...), nil
+		case 0x5:
+			return decodeAnsi(raw), nil
+		}
+		units := make([]uint16, count)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(raw[i*2:])
+		}
+		return string(utf16.Decode(units)), nil
+
+	case 0x8:
+		raw, err := p.bytes(off, int(info)+1)
+		if err != nil {
+			return nil, err
+		}
+		return readBigEndian(raw), nil
+
+	case 0xA, 0xC:
+		count, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			value, err := p.ref(off, i)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, nil
+
+	case 0xD:
+		count, off, err := p.count(info, off)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]interface{}, count)
+		for i := 0; i < count; i++ {
+			key, err := p.ref(off, i)
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("binary plist object %d has a key that isn't a string", ref)
+			}
+			value, err := p.ref(off, count+i)
+			if err != nil {
+				return nil, err
+			}
+			dict[k] = value
+		}
+		return dict, nil
+	}
+
+	return nil, fmt.Errorf("binary plist object %d has unknown marker %#x", ref, marker)
+}
+
+// count reads the length of a variable sized object, which is either the low nibble of the
+// marker or, when that's 0xF, an integer object following it.
+func (p *binaryPlist) count(info byte, off uint64) (int, uint64, error) {
+	if info != 0x0F {
+		return int(info), off, nil
+	}
+	if off >= uint64(len(p.data)) || p.data[off]>>4 != 0x1 {
+		return 0, 0, errors.New("binary plist has an invalid object length")
+	}
+	size := 1 << (p.data[off] & 0x0F)
+	raw, err := p.bytes(off+1, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	count := readBigEndian(raw)
+	if count > uint64(len(p.data)) {
+		return 0, 0, errors.New("binary plist has an invalid object length")
+	}
+	return int(count), off + 1 + uint64(size), nil
+}
+
+// ref decodes the object referenced by the i-th object reference at off.
+func (p *binaryPlist) ref(off uint64, i int) (interface{}, error) {
+	raw, err := p.bytes(off+uint64(i*p.objectRefSize), p.objectRefSize)
+	if err != nil {
+		return nil, err
+	}
+	return p.object(readBigEndian(raw))
+}
+
+func (p *binaryPlist) bytes(off uint64, n int) ([]byte, error) {
+	if n < 0 || off > uint64(len(p.data)) || uint64(n) > uint64(len(p.data))-off {
+		return nil, errors.New("binary plist object is truncated")
+	}
+	return p.data[off : off+uint64(n)], nil
+}
+
+func readBigEndian(raw []byte) uint64 {
+	var v uint64
+	for _, b := range raw {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
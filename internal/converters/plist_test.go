@@ -0,0 +1,142 @@
+package converters
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures are written by testdata/plist/generate.py.
+
+func TestDecodePlist(t *testing.T) {
+	values := map[string]interface{}{
+		"String":   "Hello, plist",
+		"Unicode":  "Grüße – 日本語 🙂",
+		"Integer":  int64(42),
+		"Negative": int64(-7),
+		"Large":    int64(1<<40 + 1),
+		"Real":     3.25,
+		"True":     true,
+		"False":    false,
+		"Date":     time.Date(2024, time.March, 1, 12, 30, 15, 0, time.UTC),
+		"Data":     []byte("\x00\x01binary\xff"),
+		"Array":    []interface{}{"a", int64(1), false},
+		"Empty":    map[string]interface{}{},
+		"Nested":   map[string]interface{}{"Inner": map[string]interface{}{"Key": "value"}},
+	}
+	bookmark := map[string]interface{}{
+		"URL":   "https://example.com/from-webloc",
+		"Title": "Example bookmark",
+	}
+
+	tests := []struct {
+		file string
+		want interface{}
+	}{
+		{file: "values.xml.plist", want: values},
+		{file: "values.binary.plist", want: values},
+		{file: "bookmark.xml.plist", want: bookmark},
+		{file: "bookmark.binary.plist", want: bookmark},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "plist", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := DecodePlist(data)
+			if err != nil {
+				t.Fatalf("DecodePlist() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodePlist() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodePlistSharedObjects(t *testing.T) {
+	// 40 levels of arrays referencing the level below twice, decoding every reference again
+	// would take 2^40 steps
+	data, err := os.ReadFile(filepath.Join("testdata", "plist", "shared.binary.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodePlist(data)
+	if err != nil {
+		t.Fatalf("DecodePlist() error = %v", err)
+	}
+	depth := 0
+	for {
+		array, ok := got.([]interface{})
+		if !ok || len(array) == 0 {
+			t.Fatalf("level %d is %#v", depth, got)
+		}
+		if len(array) == 1 {
+			if array[0] != "leaf" {
+				t.Fatalf("bottom is %#v, want leaf", array[0])
+			}
+			break
+		}
+		got = array[1]
+		depth++
+	}
+	if depth != 40 {
+		t.Errorf("depth = %d, want 40", depth)
+	}
+}
+
+func TestDecodePlistMalformed(t *testing.T) {
+	bookmark, err := os.ReadFile(filepath.Join("testdata", "plist", "bookmark.binary.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The objects of bookmark.binary.plist: the dict at 0x08 referencing the keys 1 and 2 and
+	// the values 3 and 4, "Title" at 0x0D, "URL" at 0x13 and the two values at 0x17 and 0x2A,
+	// whose length is at 0x2C. The offset table starts at 0x4C and the trailer at 0x51.
+	patched := func(patches map[int]byte) string {
+		data := append([]byte{}, bookmark...)
+		for i, b := range patches {
+			data[i] = b
+		}
+		return string(data)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "empty", data: "", wantErr: "plist has no value"},
+		{name: "binary without trailer", data: string(bookmark[:30]), wantErr: "too short"},
+		{name: "binary cut off", data: string(bookmark[:len(bookmark)-8]), wantErr: "invalid"},
+		{name: "binary offset size", data: patched(map[int]byte{0x51 + 6: 0}), wantErr: "invalid trailer"},
+		{name: "binary object count", data: patched(map[int]byte{0x51 + 8: 0xFF}), wantErr: "invalid offset table"},
+		{name: "binary offset out of bounds", data: patched(map[int]byte{0x4C + 4: 0xF0}), wantErr: "out of bounds"},
+		{name: "binary string length", data: patched(map[int]byte{0x2C: 0x60}), wantErr: "truncated"},
+		{name: "binary missing object", data: patched(map[int]byte{0x09: 0x09}), wantErr: "missing object 9"},
+		{name: "binary cycle", data: patched(map[int]byte{0x0B: 0x00}), wantErr: "references itself"},
+		{name: "binary unknown marker", data: patched(map[int]byte{0x0D: 0x75}), wantErr: "unknown marker"},
+		{name: "binary key not a string", data: patched(map[int]byte{0x13: 0x09}), wantErr: "key that isn't a string"},
+		{name: "xml cut off", data: "<plist><dict><key>URL</key><string>https://example.com", wantErr: "unexpected EOF"},
+		{name: "xml value without key", data: "<plist><dict><string>https://example.com</string></dict></plist>", wantErr: "without a key"},
+		{name: "xml unknown element", data: "<plist><url>https://example.com</url></plist>", wantErr: "unknown plist element <url>"},
+		{name: "xml bad integer", data: "<plist><integer>forty-two</integer></plist>", wantErr: "invalid syntax"},
+		{name: "xml bad date", data: "<plist><date>yesterday</date></plist>", wantErr: "cannot parse"},
+		{name: "not a plist", data: "URL=https://example.com", wantErr: "plist has no value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePlist([]byte(tt.data))
+			if err == nil {
+				t.Fatalf("DecodePlist() = %#v, want an error containing %q", got, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DecodePlist() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
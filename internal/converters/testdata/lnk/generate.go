@@ -0,0 +1,449 @@
+//go:build ignore
+
+// generate writes the .lnk fixtures in this directory, laid out as described in [MS-SHLLINK]
+// like Windows Explorer writes them. Run it from this directory with `go run generate.go`.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	hasLinkTargetIDList = 1 << 0
+	hasLinkInfo         = 1 << 1
+	hasName             = 1 << 2
+	hasRelativePath     = 1 << 3
+	hasWorkingDir       = 1 << 4
+	hasArguments        = 1 << 5
+	hasIconLocation     = 1 << 6
+	isUnicode           = 1 << 7
+	hasExpString        = 1 << 9
+
+	fileAttributeArchive = 0x20
+
+	// 2023-05-14 09:30:00 UTC as a FILETIME
+	fileTime = 133285878000000000
+)
+
+var (
+	myComputer      = guid("20D04FE0-3AEA-1069-A2D8-08002B30309D")
+	internetShell   = guid("871C5380-42A0-1069-A2EA-08002B30309D")
+	programFilesX86 = guid("7C5A40EF-A0FB-4BFC-874A-C0F2E0B9FA8E")
+)
+
+// guid encodes a GUID in its mixed endian binary form.
+func guid(s string) []byte {
+	raw, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil {
+		panic(err)
+	}
+	return []byte{
+		raw[3], raw[2], raw[1], raw[0], raw[5], raw[4], raw[7], raw[6],
+		raw[8], raw[9], raw[10], raw[11], raw[12], raw[13], raw[14], raw[15],
+	}
+}
+
+func le16(b *bytes.Buffer, v uint16) { binary.Write(b, binary.LittleEndian, v) }
+func le32(b *bytes.Buffer, v uint32) { binary.Write(b, binary.LittleEndian, v) }
+
+func utf16z(s string) []byte {
+	var b bytes.Buffer
+	for _, u := range utf16.Encode([]rune(s)) {
+		le16(&b, u)
+	}
+	le16(&b, 0)
+	return b.Bytes()
+}
+
+func ansiz(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+// windows1252z encodes s in the Western code page like Explorer does on an English or German
+// Windows, characters the code page lacks become '?'.
+func windows1252z(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		if r < 0x80 || (r >= 0xA0 && r <= 0xFF) {
+			b = append(b, byte(r))
+		} else {
+			b = append(b, '?')
+		}
+	}
+	return append(b, 0)
+}
+
+// fixed pads data with NULs to n bytes.
+func fixed(data []byte, n int) []byte {
+	return append(data, make([]byte, n-len(data))...)
+}
+
+type shortcut struct {
+	flags      uint32
+	idList     [][]byte
+	linkInfo   []byte
+	name       string
+	relPath    string
+	workingDir string
+	arguments  string
+	icon       string
+	extraData  [][]byte
+}
+
+func (s shortcut) bytes() []byte {
+	var b bytes.Buffer
+
+	// ShellLinkHeader
+	le32(&b, 0x4C)
+	b.Write(guid("00021401-0000-0000-C000-000000000046"))
+	le32(&b, s.flags)
+	le32(&b, fileAttributeArchive)
+	for i := 0; i < 3; i++ {
+		binary.Write(&b, binary.LittleEndian, uint64(fileTime))
+	}
+	le32(&b, 1024) // FileSize
+	le32(&b, 0)    // IconIndex
+	le32(&b, 1)    // SW_SHOWNORMAL
+	le16(&b, 0)    // HotKey
+	b.Write(make([]byte, 10))
+
+	if s.flags&hasLinkTargetIDList != 0 {
+		var items bytes.Buffer
+		for _, item := range s.idList {
+			le16(&items, uint16(len(item)+2))
+			items.Write(item)
+		}
+		le16(&items, 0)
+		le16(&b, uint16(items.Len()))
+		b.Write(items.Bytes())
+	}
+
+	if s.flags&hasLinkInfo != 0 {
+		b.Write(s.linkInfo)
+	}
+
+	for _, field := range []struct {
+		flag  uint32
+		value string
+	}{
+		{hasName, s.name},
+		{hasRelativePath, s.relPath},
+		{hasWorkingDir, s.workingDir},
+		{hasArguments, s.arguments},
+		{hasIconLocation, s.icon},
+	} {
+		if s.flags&field.flag == 0 {
+			continue
+		}
+		if s.flags&isUnicode != 0 {
+			units := utf16.Encode([]rune(field.value))
+			le16(&b, uint16(len(units)))
+			for _, u := range units {
+				le16(&b, u)
+			}
+		} else {
+			le16(&b, uint16(len(field.value)))
+			b.WriteString(field.value)
+		}
+	}
+
+	for _, block := range s.extraData {
+		b.Write(block)
+	}
+	le32(&b, 0) // TerminalBlock
+	return b.Bytes()
+}
+
+// rootItem is the shell item of a root folder like My Computer.
+func rootItem(clsid []byte) []byte {
+	return append([]byte{0x1F, 0x50}, clsid...)
+}
+
+func driveItem(drive string) []byte {
+	return append([]byte{0x2F}, fixed([]byte(drive), 22)...)
+}
+
+// fileItem is a file entry shell item without extension blocks.
+func fileItem(name string, dir bool) []byte {
+	var b bytes.Buffer
+	if dir {
+		b.WriteByte(0x31)
+	} else {
+		b.WriteByte(0x32)
+	}
+	b.WriteByte(0)
+	le32(&b, 1024)
+	le16(&b, 0x56AE) // 2023-05-14
+	le16(&b, 0x4BC0) // 09:30:00
+	le16(&b, fileAttributeArchive)
+	b.Write(ansiz(name))
+	if b.Len()%2 != 0 {
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+// longFileItem is a file entry shell item like Explorer writes it, with the 8.3 name in the
+// item and the long name in a version 9 extension block (0xBEEF0004).
+func longFileItem(shortName string, longName string, dir bool) []byte {
+	b := bytes.NewBuffer(fileItem(shortName, dir))
+
+	var ext bytes.Buffer
+	le16(&ext, 0) // size, set below
+	le16(&ext, 9)
+	le32(&ext, 0xBEEF0004)
+	le16(&ext, 0x56AE) // created 2023-05-14
+	le16(&ext, 0x4BC0)
+	le16(&ext, 0x56AE) // accessed 2023-05-14
+	le16(&ext, 0x4BC0)
+	le16(&ext, 0x2E)
+	le16(&ext, 0)
+	binary.Write(&ext, binary.LittleEndian, uint64(0x0001000000012E4F)) // NTFS file reference
+	binary.Write(&ext, binary.LittleEndian, uint64(0))
+	le16(&ext, 0) // no localized name
+	le32(&ext, 0)
+	le32(&ext, 0)
+	ext.Write(utf16z(longName))
+	le16(&ext, 0x0E) // offset of the extension block version
+	raw := ext.Bytes()
+	binary.LittleEndian.PutUint16(raw, uint16(len(raw)))
+
+	b.Write(raw)
+	return b.Bytes()
+}
+
+// uriItem is the shell item Internet Explorer writes for a web address.
+func uriItem(url string) []byte {
+	var b bytes.Buffer
+	b.WriteByte(0x61)
+	b.WriteByte(0x80) // the URL is UTF-16
+	le16(&b, 0)
+	le32(&b, 0)
+	le32(&b, 0)
+	b.Write(utf16z(url))
+	return b.Bytes()
+}
+
+// localLinkInfo is a LinkInfo with a VolumeID and a local base path, with Unicode copies.
+func localLinkInfo(driveType uint32, serial uint32, label string, path string) []byte {
+	var volume bytes.Buffer
+	volumeLabel := ansiz(label)
+	le32(&volume, uint32(0x10+len(volumeLabel)))
+	le32(&volume, driveType)
+	le32(&volume, serial)
+	le32(&volume, 0x10)
+	volume.Write(volumeLabel)
+
+	const headerSize = 0x24
+	ansiPath := windows1252z(path)
+	ansiSuffix := ansiz("")
+	unicodePath := utf16z(path)
+	unicodeSuffix := utf16z("")
+
+	volumeOffset := uint32(headerSize)
+	pathOffset := volumeOffset + uint32(volume.Len())
+	suffixOffset := pathOffset + uint32(len(ansiPath))
+	unicodePathOffset := suffixOffset + uint32(len(ansiSuffix))
+	unicodeSuffixOffset := unicodePathOffset + uint32(len(unicodePath))
+	size := unicodeSuffixOffset + uint32(len(unicodeSuffix))
+
+	var b bytes.Buffer
+	le32(&b, size)
+	le32(&b, headerSize)
+	le32(&b, 1) // VolumeIDAndLocalBasePath
+	le32(&b, volumeOffset)
+	le32(&b, pathOffset)
+	le32(&b, 0)
+	le32(&b, suffixOffset)
+	le32(&b, unicodePathOffset)
+	le32(&b, unicodeSuffixOffset)
+	b.Write(volume.Bytes())
+	b.Write(ansiPath)
+	b.Write(ansiSuffix)
+	b.Write(unicodePath)
+	b.Write(unicodeSuffix)
+	return b.Bytes()
+}
+
+// networkLinkInfo is a LinkInfo of a file on a share mapped to a drive letter.
+func networkLinkInfo(netName string, device string, suffix string) []byte {
+	var link bytes.Buffer
+	netNameBytes := ansiz(netName)
+	deviceBytes := ansiz(device)
+	le32(&link, uint32(0x14+len(netNameBytes)+len(deviceBytes)))
+	le32(&link, 0x3) // ValidDevice | ValidNetType
+	le32(&link, 0x14)
+	le32(&link, uint32(0x14+len(netNameBytes)))
+	le32(&link, 0x00020000) // WNNC_NET_LANMAN
+	link.Write(netNameBytes)
+	link.Write(deviceBytes)
+
+	const headerSize = 0x1C
+	linkOffset := uint32(headerSize)
+	suffixOffset := linkOffset + uint32(link.Len())
+	suffixBytes := ansiz(suffix)
+
+	var b bytes.Buffer
+	le32(&b, suffixOffset+uint32(len(suffixBytes)))
+	le32(&b, headerSize)
+	le32(&b, 2) // CommonNetworkRelativeLinkAndPathSuffix
+	le32(&b, 0)
+	le32(&b, 0)
+	le32(&b, linkOffset)
+	le32(&b, suffixOffset)
+	b.Write(link.Bytes())
+	b.Write(suffixBytes)
+	return b.Bytes()
+}
+
+func environmentBlock(target string) []byte {
+	var b bytes.Buffer
+	le32(&b, 0x314)
+	le32(&b, 0xA0000001)
+	b.Write(fixed(ansiz(target), 260))
+	b.Write(fixed(utf16z(target), 520))
+	return b.Bytes()
+}
+
+func trackerBlock(machine string) []byte {
+	var b bytes.Buffer
+	le32(&b, 0x60)
+	le32(&b, 0xA0000003)
+	le32(&b, 0x58)
+	le32(&b, 0)
+	b.Write(fixed([]byte(machine), 16))
+	droid := guid("EC46CD7B-227F-11E3-8A7A-001C42D9E5A4")
+	birth := guid("0A0E8B2C-F2D7-11E2-8B17-001C42D9E5A4")
+	b.Write(droid)
+	b.Write(birth)
+	b.Write(droid)
+	b.Write(birth)
+	return b.Bytes()
+}
+
+func specialFolderBlock(id uint32, offset uint32) []byte {
+	var b bytes.Buffer
+	le32(&b, 0x10)
+	le32(&b, 0xA0000005)
+	le32(&b, id)
+	le32(&b, offset)
+	return b.Bytes()
+}
+
+func knownFolderBlock(folder []byte, offset uint32) []byte {
+	var b bytes.Buffer
+	le32(&b, 0x1C)
+	le32(&b, 0xA000000B)
+	b.Write(folder)
+	le32(&b, offset)
+	return b.Bytes()
+}
+
+// propertyStoreBlock holds an empty serialized property storage.
+func propertyStoreBlock() []byte {
+	var b bytes.Buffer
+	le32(&b, 0x0C)
+	le32(&b, 0xA0000009)
+	le32(&b, 0)
+	return b.Bytes()
+}
+
+func main() {
+	fixtures := map[string]shortcut{
+		// A shortcut to a local file, like the example in section 3.1 of [MS-SHLLINK]
+		"local.lnk": {
+			flags: hasLinkTargetIDList | hasLinkInfo | hasRelativePath | hasWorkingDir | isUnicode,
+			idList: [][]byte{
+				rootItem(myComputer),
+				driveItem(`C:\`),
+				fileItem("test", true),
+				fileItem("a.txt", false),
+			},
+			linkInfo:   localLinkInfo(3, 0x307A8A81, "", `C:\test\a.txt`),
+			relPath:    `.\a.txt`,
+			workingDir: `C:\test`,
+			extraData:  [][]byte{trackerBlock("chris-xps")},
+		},
+
+		// A shortcut to a document on a share mapped to Z:, written with ANSI strings
+		"network.lnk": {
+			flags:      hasLinkInfo | hasName | hasWorkingDir,
+			linkInfo:   networkLinkInfo(`\\FILESERVER\TEAM`, "Z:", `docs\report.docx`),
+			name:       "Quarterly report",
+			workingDir: `Z:\docs`,
+			extraData:  [][]byte{trackerBlock("fileserver")},
+		},
+
+		// A shortcut Internet Explorer creates for a web address
+		"url.lnk": {
+			flags: hasLinkTargetIDList | hasName | isUnicode,
+			idList: [][]byte{
+				rootItem(internetShell),
+				uriItem("https://example.com/from-lnk"),
+			},
+			name:      "Example page",
+			extraData: [][]byte{propertyStoreBlock()},
+		},
+
+		// A shortcut that opens a page in a browser through its arguments
+		"arguments.lnk": {
+			flags: hasLinkTargetIDList | hasLinkInfo | hasName | hasWorkingDir | hasArguments | hasIconLocation | isUnicode | hasExpString,
+			idList: [][]byte{
+				rootItem(myComputer),
+				driveItem(`C:\`),
+				fileItem("PROGRA~2", true),
+				fileItem("MOZILL~1", true),
+				fileItem("firefox.exe", false),
+			},
+			linkInfo:   localLinkInfo(3, 0x1C4E9A02, "Windows", `C:\Program Files (x86)\Mozilla Firefox\firefox.exe`),
+			name:       "Go documentation",
+			workingDir: `C:\Program Files (x86)\Mozilla Firefox`,
+			arguments:  `-new-tab "https://go.dev/doc/"`,
+			icon:       `%ProgramFiles(x86)%\Mozilla Firefox\firefox.exe`,
+			extraData: [][]byte{
+				environmentBlock(`%ProgramFiles(x86)%\Mozilla Firefox\firefox.exe`),
+				specialFolderBlock(0x2A, 0x14), // CSIDL_PROGRAM_FILESX86
+				knownFolderBlock(programFilesX86, 0x14),
+				trackerBlock("desktop-7k2"),
+				propertyStoreBlock(),
+			},
+		},
+
+		// A shortcut Explorer creates with "Create shortcut" for a file with a name the ANSI
+		// code page can't hold, commented in its properties
+		"explorer.lnk": {
+			flags: hasLinkTargetIDList | hasLinkInfo | hasName | hasRelativePath | hasWorkingDir | isUnicode,
+			idList: [][]byte{
+				rootItem(myComputer),
+				driveItem(`C:\`),
+				longFileItem("Users", "Users", true),
+				longFileItem("ZO~1", "Zoë", true),
+				longFileItem("DOCUME~1", "Documents", true),
+				longFileItem("RSUM~1.DOC", "Résumé 日本語.docx", false),
+			},
+			linkInfo:   localLinkInfo(3, 0x5A3C91E4, "", `C:\Users\Zoë\Documents\Résumé 日本語.docx`),
+			name:       "Lebenslauf für Bewerbungen – 2024",
+			relPath:    `..\Documents\Résumé 日本語.docx`,
+			workingDir: `C:\Users\Zoë\Documents`,
+			extraData:  [][]byte{trackerBlock("zoe-laptop"), propertyStoreBlock()},
+		},
+
+		// A shortcut that only records its target through environment variables
+		"environment.lnk": {
+			flags:     hasRelativePath | isUnicode | hasExpString,
+			relPath:   `..\..\Windows\notepad.exe`,
+			extraData: [][]byte{environmentBlock(`%windir%\notepad.exe`)},
+		},
+	}
+
+	for name, s := range fixtures {
+		if err := os.WriteFile(name, s.bytes(), 0644); err != nil {
+			panic(err)
+		}
+	}
+}
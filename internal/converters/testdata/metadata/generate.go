@@ -0,0 +1,77 @@
+//go:build ignore
+
+// generate writes the .docx and .odt fixtures in this directory, trimmed down to the files
+// Word and LibreOffice put in every document plus the metadata. Run it from this directory
+// with `go run generate.go`.
+package main
+
+import (
+	"archive/zip"
+	"os"
+)
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/><Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/></Types>`
+
+const docxDocument = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body><w:p><w:r><w:t>Hello from Word.</w:t></w:r></w:p></w:body></w:document>`
+
+const docxCore = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:dcmitype="http://purl.org/dc/dcmitype/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><dc:title>Quarterly report</dc:title><dc:subject>Numbers of the first quarter</dc:subject><dc:creator>Ada Lovelace</dc:creator><cp:keywords>finance; reports</cp:keywords><dc:description></dc:description><cp:lastModifiedBy>Ada Lovelace</cp:lastModifiedBy><cp:revision>3</cp:revision><dcterms:created xsi:type="dcterms:W3CDTF">2024-02-10T09:15:00Z</dcterms:created><dcterms:modified xsi:type="dcterms:W3CDTF">2024-02-11T17:00:00Z</dcterms:modified></cp:coreProperties>`
+
+const odtMimetype = "application/vnd.oasis.opendocument.text"
+
+const odtContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" office:version="1.3"><office:body><office:text><text:p>Hello from LibreOffice.</text:p></office:text></office:body></office:document-content>`
+
+const odtMeta = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-meta xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/" office:version="1.3"><office:meta><meta:creation-date>2024-01-05T10:00:00.123456789</meta:creation-date><dc:date>2024-01-06T08:00:00.000000000</dc:date><meta:editing-duration>PT5M</meta:editing-duration><meta:generator>LibreOffice/7.6.4.1$Linux_X86_64 LibreOffice_project/60$Build-1</meta:generator><dc:title>Meeting notes</dc:title><dc:subject>Planning</dc:subject><meta:keyword>meetings</meta:keyword><meta:keyword>planning</meta:keyword><meta:initial-creator>Grace Hopper</meta:initial-creator></office:meta></office:document-meta>`
+
+func write(name string, files [][2]string) {
+	out, err := os.Create(name)
+	if err != nil {
+		panic(err)
+	}
+	defer out.Close()
+
+	archive := zip.NewWriter(out)
+	for _, file := range files {
+		// The mimetype of OpenDocument files is stored uncompressed
+		header := &zip.FileHeader{Name: file[0], Method: zip.Deflate}
+		if file[0] == "mimetype" {
+			header.Method = zip.Store
+		}
+		w, err := archive.CreateHeader(header)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := w.Write([]byte(file[1])); err != nil {
+			panic(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	write("report.docx", [][2]string{
+		{"[Content_Types].xml", docxContentTypes},
+		{"word/document.xml", docxDocument},
+		{"docProps/core.xml", docxCore},
+	})
+	// Documents written by some tools have no core properties at all
+	write("no-core.docx", [][2]string{
+		{"[Content_Types].xml", docxContentTypes},
+		{"word/document.xml", docxDocument},
+	})
+	write("notes.odt", [][2]string{
+		{"mimetype", odtMimetype},
+		{"content.xml", odtContent},
+		{"meta.xml", odtMeta},
+	})
+	write("no-meta.odt", [][2]string{
+		{"mimetype", odtMimetype},
+		{"content.xml", odtContent},
+	})
+}
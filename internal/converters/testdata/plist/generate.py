@@ -0,0 +1,44 @@
+"""Writes the plist fixtures with Python's plistlib, an implementation of the XML and
+binary (bplist00) formats independent of the decoder under test.
+
+Run it from this directory with: python3 generate.py
+"""
+
+import datetime
+import plistlib
+
+values = {
+    "String": "Hello, plist",
+    "Unicode": "Grüße – 日本語 🙂",
+    "Integer": 42,
+    "Negative": -7,
+    "Large": 2**40 + 1,
+    "Real": 3.25,
+    "True": True,
+    "False": False,
+    "Date": datetime.datetime(2024, 3, 1, 12, 30, 15),
+    "Data": b"\x00\x01binary\xff",
+    "Array": ["a", 1, False],
+    "Empty": {},
+    "Nested": {"Inner": {"Key": "value"}},
+}
+
+bookmark = {"URL": "https://example.com/from-webloc", "Title": "Example bookmark"}
+
+# Every level references the level below twice, a decoder that doesn't reuse decoded objects
+# visits the bottom 2^depth times
+shared = ["leaf"]
+for _ in range(40):
+    shared = [shared, shared]
+
+fixtures = {
+    "values.xml.plist": (values, plistlib.FMT_XML),
+    "values.binary.plist": (values, plistlib.FMT_BINARY),
+    "bookmark.xml.plist": (bookmark, plistlib.FMT_XML),
+    "bookmark.binary.plist": (bookmark, plistlib.FMT_BINARY),
+    "shared.binary.plist": (shared, plistlib.FMT_BINARY),
+}
+
+for name, (value, fmt) in fixtures.items():
+    with open(name, "wb") as f:
+        plistlib.dump(value, f, fmt=fmt, sort_keys=True)
@@ -0,0 +1,70 @@
+package converters
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	Register(internetShortcutConverter{})
+}
+
+// internetShortcutConverter reads Windows .url files, which are INI files with the link in
+// the URL key of the [InternetShortcut] section.
+type internetShortcutConverter struct{}
+
+func (internetShortcutConverter) Name() string {
+	return "url"
+}
+
+func (internetShortcutConverter) Extensions() []string {
+	return []string{".url"}
+}
+
+func (internetShortcutConverter) ConvertsLinks() bool {
+	return true
+}
+
+func (internetShortcutConverter) Convert(inputPath string, outputPath string) (Result, error) {
+	link, err := ExtractLinkFromInternetShortcut(inputPath)
+	if err != nil {
+		log.Error().Err(err).Str("input", inputPath).Msg("Failed to extract link from url.")
+		return Result{}, err
+	}
+	return Result{Metadata: Metadata{Link: link}}, nil
+}
+
+func ExtractLinkFromInternetShortcut(inputPath string) (string, error) {
+	file, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.EqualFold(section, "InternetShortcut") && strings.EqualFold(strings.TrimSpace(key), "URL") {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("no URL found in the internet shortcut file")
+}
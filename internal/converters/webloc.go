@@ -1,42 +1,107 @@
 package converters
 
 import (
-	"encoding/xml"
-	"io/ioutil"
-	"path/filepath"
+	"errors"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/rs/zerolog/log"
 )
 
+// weblocTitleKeys are the dictionary keys apps store a bookmark's title under.
+var weblocTitleKeys = []string{"Title", "title", "Name", "name", "URIDictionary.title"}
+
+// Webloc is a macOS bookmark.
 type Webloc struct {
-	XMLName xml.Name `xml:"plist"`
-	Dict    struct {
-		Key    string `xml:"key"`
-		String string `xml:"string"`
-	} `xml:"dict"`
+	Url   string
+	Title string
+}
+
+func init() {
+	Register(weblocConverter{})
 }
 
-func ExtractLinkFromWebloc(inputDir string, inputFileRelPath string) (string, error) {
-	inputDirAbs, err := filepath.Abs(inputDir)
+type weblocConverter struct{}
+
+func (weblocConverter) Name() string {
+	return "webloc"
+}
+
+func (weblocConverter) Extensions() []string {
+	return []string{".webloc"}
+}
+
+func (weblocConverter) ConvertsLinks() bool {
+	return true
+}
+
+func (weblocConverter) Convert(inputPath string, outputPath string) (Result, error) {
+	webloc, err := ParseWebloc(inputPath)
 	if err != nil {
-		log.Error().Err(err).Str("inputDirAbs", inputDir).Msg("Failed to get input absolute path.")
-		return "", err
+		log.Error().Err(err).Str("input", inputPath).Msg("Failed to extract link from webloc.")
+		return Result{}, err
 	}
-	inputFileAbsPath := filepath.Join(inputDirAbs, inputFileRelPath)
+	return Result{Metadata: Metadata{Link: webloc.Url, Title: webloc.Title}}, nil
+}
 
-	// Read the contents of the webloc file
-	data, err := ioutil.ReadFile(inputFileAbsPath)
+func ExtractLinkFromWebloc(inputPath string) (string, error) {
+	webloc, err := ParseWebloc(inputPath)
 	if err != nil {
 		return "", err
 	}
+	return webloc.Url, nil
+}
 
-	// Unmarshal the XML data into a Webloc struct
-	var webloc Webloc
-	err = xml.Unmarshal(data, &webloc)
+// ParseWebloc reads the URL and title of a .webloc file, which is an XML or binary plist.
+func ParseWebloc(inputPath string) (Webloc, error) {
+	data, err := os.ReadFile(inputPath)
 	if err != nil {
-		return "", err
+		return Webloc{}, err
+	}
+
+	value, err := DecodePlist(data)
+	if err != nil {
+		return Webloc{}, err
+	}
+	dict, ok := value.(map[string]interface{})
+	if !ok {
+		return Webloc{}, errors.New("webloc plist isn't a dictionary")
+	}
+
+	// The key is "URL", other spellings are only looked at in a fixed order when it's missing
+	var webloc Webloc
+	if s, ok := dict["URL"].(string); ok {
+		webloc.Url = strings.TrimSpace(s)
+	} else {
+		var keys []string
+		for key := range dict {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if s, ok := dict[key].(string); ok && strings.EqualFold(key, "URL") {
+				webloc.Url = strings.TrimSpace(s)
+				break
+			}
+		}
+	}
+	if webloc.Url == "" {
+		return Webloc{}, errors.New("no URL found in the webloc file")
+	}
+
+	for _, key := range weblocTitleKeys {
+		// Some apps nest the title in a URIDictionary like in Safari's bookmarks
+		parent, name := dict, key
+		if before, after, ok := strings.Cut(key, "."); ok {
+			nested, _ := dict[before].(map[string]interface{})
+			parent, name = nested, after
+		}
+		if s, ok := parent[name].(string); ok && s != "" {
+			webloc.Title = s
+			break
+		}
 	}
 
-	// Extract and return the URL
-	return webloc.Dict.String, nil
+	return webloc, nil
 }
@@ -0,0 +1,71 @@
+package converters
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseWebloc(t *testing.T) {
+	plist := func(body string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?><plist version="1.0"><dict>` + body + `</dict></plist>`
+	}
+
+	tests := []struct {
+		name      string
+		file      string
+		contents  string
+		wantUrl   string
+		wantTitle string
+		wantErr   bool
+	}{
+		{name: "xml", file: "bookmark.xml.plist", wantUrl: "https://example.com/from-webloc", wantTitle: "Example bookmark"},
+		{name: "binary", file: "bookmark.binary.plist", wantUrl: "https://example.com/from-webloc", wantTitle: "Example bookmark"},
+		{
+			name:     "exact key wins",
+			contents: plist(`<key>url</key><string>https://example.com/lower</string><key>URL</key><string>https://example.com/exact</string><key>Url</key><string>https://example.com/mixed</string>`),
+			wantUrl:  "https://example.com/exact",
+		},
+		{
+			name:     "other spellings in sorted order",
+			contents: plist(`<key>url</key><string>https://example.com/lower</string><key>Url</key><string>https://example.com/mixed</string>`),
+			wantUrl:  "https://example.com/mixed",
+		},
+		{
+			name:     "exact key that isn't a string",
+			contents: plist(`<key>URL</key><integer>1</integer><key>url</key><string>https://example.com/lower</string>`),
+			wantUrl:  "https://example.com/lower",
+		},
+		{
+			name:      "nested title",
+			contents:  plist(`<key>URL</key><string> https://example.com/ </string><key>URIDictionary</key><dict><key>title</key><string>Safari</string></dict>`),
+			wantUrl:   "https://example.com/",
+			wantTitle: "Safari",
+		},
+		{name: "no url", contents: plist(`<key>Title</key><string>Nothing</string>`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join("testdata", "plist", tt.file)
+			if tt.file == "" {
+				path = filepath.Join(t.TempDir(), "bookmark.webloc")
+				if err := os.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := ParseWebloc(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseWebloc() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWebloc() error = %v", err)
+			}
+			if got.Url != tt.wantUrl || got.Title != tt.wantTitle {
+				t.Errorf("ParseWebloc() = %+v, want {Url:%s Title:%s}", got, tt.wantUrl, tt.wantTitle)
+			}
+		})
+	}
+}
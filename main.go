@@ -72,6 +72,18 @@ func main() {
 				Name:  "watch",
 				Value: false,
 			},
+			&cli.BoolFlag{
+				Name:  "drafts",
+				Usage: "include drafts, on by default with --watch",
+			},
+			&cli.BoolFlag{
+				Name:  "future",
+				Usage: "include pages whose publish_at is in the future, on by default with --watch",
+			},
+			&cli.IntFlag{
+				Name:  "jobs",
+				Usage: "number of files to convert concurrently, defaults to the number of CPUs",
+			},
 			&cli.StringFlag{
 				Name:     "addr",
 				Usage:    "Address to serve, defaults to `:8080`",
@@ -87,14 +99,33 @@ func main() {
 				return err
 			}
 
+			if cCtx.IsSet("jobs") {
+				config.Jobs = cCtx.Int("jobs")
+			}
+
+			// The dev server shows everything that's being worked on unless told otherwise
+			if cCtx.IsSet("drafts") {
+				config.Drafts = cCtx.Bool("drafts")
+			} else if cCtx.Bool("watch") {
+				config.Drafts = true
+			}
+			if cCtx.IsSet("future") {
+				config.Future = cCtx.Bool("future")
+			} else if cCtx.Bool("watch") {
+				config.Future = true
+			}
+
 			if cCtx.Bool("watch") {
 
 				wg := sync.WaitGroup{}
 				wg.Add(1) // Add the server to wait group
 
+				// Browsers are told to reload after every successful rebuild
+				reload := application.NewLiveReload()
+
 				// Run the file watcher in a separate goroutine
 				go func() {
-					err := application.WatchInputDirectory(config)
+					err := application.WatchInputDirectory(config, reload)
 					if err != nil {
 						if errors.Is(err, os.ErrPermission) {
 							log.Fatal().Err(err).Msg("Insufficient permissions.")
@@ -106,7 +137,7 @@ func main() {
 
 				addr := cCtx.String("addr")
 
-				err := application.ServeOutputDirectory(config.BuildPath, addr, &wg)
+				err := application.ServeOutputDirectory(config.BuildPath, addr, &wg, reload)
 				if err != nil {
 					if errors.Is(err, os.ErrPermission) {
 						log.Fatal().Err(err).Msg("Insufficient permissions.")